@@ -2,27 +2,37 @@ package YARPC
 
 import (
 	"YARPC/codec"
+	"bufio"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net"
+	"net/http"
+	"strings"
 	"sync"
+	"time"
 )
 
 //结构体Call代表一次活跃的RPC调用
 type Call struct {
 	Seq           uint64
-	ServiceMethod string      //格式为"<service>.<method>"
-	Args          interface{} //调用参数
-	Reply         interface{} //函数返回值
-	Error         error       //函数调用如果发生错误，该值应该就会被设定
-	Done          chan *Call  //告知本次调用是否已经完成，用于支持异步调用
+	ServiceMethod string            //格式为"<service>.<method>"
+	Args          interface{}       //调用参数
+	Reply         interface{}       //函数返回值
+	Error         error             //函数调用如果发生错误，该值应该就会被设定
+	Done          chan *Call        //告知本次调用是否已经完成，用于支持异步调用
+	Metadata      map[string]string //随请求发送的元数据，GoContext会用ctx中通过WithMetadata设置的元数据初始化它
+	Trailer       map[string]string //服务端通过SetTrailer返回的元数据，在调用完成后有效
+	finished      chan struct{}     //调用结束时被关闭，用于结束对ctx的监听
 }
 
 //当调用结束时，通过调用call.Done()去通知调用方
+//每个call只会被done一次：只有将call从client.pending中移除的一方才能调用done
 func (call *Call) done() {
+	close(call.finished)
 	call.Done <- call
 }
 
@@ -36,6 +46,8 @@ type Client struct {
 	pending  map[uint64]*Call //存储未处理完的请求，键是编号，值是Call实例
 	closing  bool             //用户端调用了Close
 	shutdown bool             //服务端要求停止程序，一般是有错误发生
+	//通过Use添加的拦截器
+	interceptors []ClientInterceptor
 }
 
 var _ io.Closer = (*Client)(nil)
@@ -92,7 +104,8 @@ func (client *Client) terminateCalls(err error) {
 	client.mu.Lock()
 	defer client.mu.Unlock()
 	client.shutdown = true
-	for _, call := range client.pending {
+	for seq, call := range client.pending {
+		delete(client.pending, seq)
 		call.Error = err
 		call.done()
 	}
@@ -111,10 +124,12 @@ func (client *Client) receive() {
 			//call不存在，可能是请求没有发送完整，或者因为其他原因被取消，但服务端仍旧处理了
 			err = client.cc.ReadBody(nil)
 		case h.Error != "":
-			call.Error = fmt.Errorf(h.Error)
+			call.Trailer = h.Metadata
+			call.Error = statusFromHeader(&h)
 			err = client.cc.ReadBody(nil)
 			call.done()
 		default:
+			call.Trailer = h.Metadata
 			err = client.cc.ReadBody(call.Reply)
 			if err != nil {
 				call.Error = errors.New("reading body " + err.Error())
@@ -136,21 +151,29 @@ func parseOptions(opts ...*Option) (*Option, error) {
 	if len(opts) != 1 {
 		return nil, errors.New("number of options is more than 1")
 	}
-	opt := opts[0]
+	//复制一份再填充默认值，同一个Option可能被多个协程同时用于Dial(例如XClient)，不能直接修改它
+	opt := *opts[0]
 	opt.MagicNumber = DefaultOption.MagicNumber
 	if opt.CodecType == "" {
 		opt.CodecType = DefaultOption.CodecType
 	}
-	return opt, nil
+	return &opt, nil
 }
 
-//用户使用Dial函数传入服务端地址，创建Client实例，为了简化用户调用，这里将opts设置为可选参数
-func Dial(network, address string, opts ...*Option) (client *Client, err error) {
+type clientResult struct {
+	client *Client
+	err    error
+}
+
+type newClientFunc func(conn net.Conn, opt *Option) (client *Client, err error)
+
+//dialTimeout在opt.ConnectTimeout内完成TCP连接和Option的发送，超时则返回错误
+func dialTimeout(f newClientFunc, network, address string, opts ...*Option) (client *Client, err error) {
 	opt, err := parseOptions(opts...)
 	if err != nil {
 		return nil, err
 	}
-	conn, err := net.Dial(network, address)
+	conn, err := net.DialTimeout(network, address, opt.ConnectTimeout)
 	if err != nil {
 		return nil, err
 	}
@@ -159,15 +182,73 @@ func Dial(network, address string, opts ...*Option) (client *Client, err error)
 	//2.然而，如果defer中修改了要返回的值，该值返回给上层函数时仍然是被defer修改后的结果
 	//理解：第1点中return之后的语句先执行，并不是说return操作会在defer之前执行，而是return之后的语句先执行，函数将返回值传递给上层调用者仍然是整个函数运行的最后一步
 	defer func() {
-		if client == nil {
+		if err != nil {
 			_ = conn.Close()
 		}
 	}()
-	return NewClient(conn, opt)
+	//创建client(发送Option)放在子协程中执行，以便对其计时
+	ch := make(chan clientResult, 1)
+	go func() {
+		client, err := f(conn, opt)
+		ch <- clientResult{client: client, err: err}
+	}()
+	if opt.ConnectTimeout == 0 {
+		result := <-ch
+		return result.client, result.err
+	}
+	select {
+	case <-time.After(opt.ConnectTimeout):
+		return nil, fmt.Errorf("rpc client: connect timeout: expect within %s", opt.ConnectTimeout)
+	case result := <-ch:
+		return result.client, result.err
+	}
 }
+
+//用户使用Dial函数传入服务端地址，创建Client实例，为了简化用户调用，这里将opts设置为可选参数
+func Dial(network, address string, opts ...*Option) (*Client, error) {
+	return dialTimeout(NewClient, network, address, opts...)
+}
+
+//NewHTTPClient先通过HTTP CONNECT请求与服务端建立连接，再在该连接上创建Client
+func NewHTTPClient(conn net.Conn, opt *Option) (*Client, error) {
+	_, _ = io.WriteString(conn, fmt.Sprintf("CONNECT %s HTTP/1.0\n\n", defaultRPCPath))
+	//在切换到RPC协议之前，必须先收到成功的HTTP响应
+	//服务端在收到Option之前不会再写入数据，因此bufio.Reader不会多读入RPC报文
+	resp, err := http.ReadResponse(bufio.NewReader(conn), &http.Request{Method: "CONNECT"})
+	if err == nil && resp.Status == connected {
+		return NewClient(conn, opt)
+	}
+	if err == nil {
+		err = errors.New("unexpected HTTP response: " + resp.Status)
+	}
+	return nil, err
+}
+
+//DialHTTP连接到一个在defaultRPCPath上监听的HTTP RPC服务端
+func DialHTTP(network, address string, opts ...*Option) (*Client, error) {
+	return dialTimeout(NewHTTPClient, network, address, opts...)
+}
+
+//XDial根据rpcAddr的协议部分选择连接方式，rpcAddr的格式为protocol@addr，例如：
+//	http@10.0.0.1:7001, tcp@10.0.0.1:9999, unix@/tmp/yarpc.sock
+func XDial(rpcAddr string, opts ...*Option) (*Client, error) {
+	parts := strings.Split(rpcAddr, "@")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("rpc client err: wrong format '%s', expect protocol@addr", rpcAddr)
+	}
+	protocol, addr := parts[0], parts[1]
+	switch protocol {
+	case "http":
+		return DialHTTP("tcp", addr, opts...)
+	default:
+		//tcp、unix或者其他net.Dial支持的协议
+		return Dial(protocol, addr, opts...)
+	}
+}
+
 func NewClient(conn net.Conn, opt *Option) (*Client, error) {
-	f := codec.NewCodecFuncMap[opt.CodecType]
-	if f == nil {
+	f, ok := codec.Lookup(opt.CodecType)
+	if !ok {
 		err := fmt.Errorf("invalid codec type %s", opt.CodecType)
 		log.Println("rpc client: codec error:", err)
 		return nil, err
@@ -191,11 +272,22 @@ func newClientCodec(cc codec.Codec, opt *Option) *Client {
 	go client.receive()
 	return client
 }
-func (client *Client) send(call *Call) {
+
+//send将ctx的剩余时间写入header.Timeout，使服务端可以放弃客户端已经不再等待的请求
+func (client *Client) send(ctx context.Context, call *Call) {
 	//保证客户端可以发送一个完整的请求
 	client.sending.Lock()
 	defer client.sending.Unlock()
 
+	var timeout time.Duration
+	if deadline, ok := ctx.Deadline(); ok {
+		if timeout = time.Until(deadline); timeout <= 0 {
+			call.Error = context.DeadlineExceeded
+			call.done()
+			return
+		}
+	}
+
 	//注册这次call
 	seq, err := client.registerCall(call)
 	if err != nil {
@@ -208,6 +300,8 @@ func (client *Client) send(call *Call) {
 	client.header.ServiceMethod = call.ServiceMethod
 	client.header.Seq = seq
 	client.header.Error = ""
+	client.header.Timeout = timeout
+	client.header.Metadata = call.Metadata
 
 	//编码并发送请求
 	if err := client.cc.Write(&client.header, call.Args); err != nil {
@@ -219,9 +313,26 @@ func (client *Client) send(call *Call) {
 	}
 }
 
+//watchContext在ctx被取消或超时时，将call从client.pending中移除，并以ctx.Err()结束这次调用
+func (client *Client) watchContext(ctx context.Context, call *Call) {
+	select {
+	case <-ctx.Done():
+		if call := client.removeCall(call.Seq); call != nil {
+			call.Error = ctx.Err()
+			call.done()
+		}
+	case <-call.finished:
+	}
+}
+
 //Go和Call是客户端暴露给用户的两个RPC服务调用接口，Go是一个异步接口，返回call实例
 //Call是对Go的封装，阻塞call.Done,等待响应返回，是一个同步接口
 func (client *Client) Go(serviceMethod string, args, reply interface{}, done chan *Call) *Call {
+	return client.GoContext(context.Background(), serviceMethod, args, reply, done)
+}
+
+//GoContext是可以被ctx取消的Go，ctx被取消或超时后，call.Error被设置为ctx.Err()
+func (client *Client) GoContext(ctx context.Context, serviceMethod string, args, reply interface{}, done chan *Call) *Call {
 	if done == nil {
 		//参数10指定了chan的长度
 		done = make(chan *Call, 10)
@@ -233,12 +344,56 @@ func (client *Client) Go(serviceMethod string, args, reply interface{}, done cha
 		Args:          args,
 		Reply:         reply,
 		Done:          done,
+		Metadata:      copyMetadata(outgoingMetadata(ctx)),
+		finished:      make(chan struct{}),
+	}
+	//保证拦截器可以直接向call.Metadata中添加元数据
+	if call.Metadata == nil {
+		call.Metadata = make(map[string]string)
 	}
-	client.send(call)
+	client.mu.Lock()
+	interceptors := client.interceptors
+	client.mu.Unlock()
+	if len(interceptors) == 0 {
+		client.start(ctx, call)
+		return call
+	}
+	//拦截器是同步执行的，因此在子协程中运行调用链，调用链返回后再通知调用方
+	go func() {
+		call.Error = chainClientInterceptors(interceptors, client.invoke)(ctx, call)
+		call.done()
+	}()
 	return call
 }
+
+//start发送call，并在ctx被取消或超时时结束这次调用
+func (client *Client) start(ctx context.Context, call *Call) {
+	if err := ctx.Err(); err != nil {
+		call.Error = err
+		call.done()
+		return
+	}
+	client.send(ctx, call)
+	//context.Background()等不会被取消的ctx，其Done()返回nil，无需监听
+	if ctx.Done() != nil {
+		go client.watchContext(ctx, call)
+	}
+}
+
+//Call在opt.CallTimeout内没有收到响应时，会将call从client.pending中移除并返回超时错误
 func (client *Client) Call(serviceMethod string, args, reply interface{}) error {
+	ctx := context.Background()
+	if client.opt.CallTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, client.opt.CallTimeout)
+		defer cancel()
+	}
+	return client.CallContext(ctx, serviceMethod, args, reply)
+}
+
+//CallContext在ctx被取消或超时时立即返回ctx.Err()，并将call从client.pending中移除
+func (client *Client) CallContext(ctx context.Context, serviceMethod string, args, reply interface{}) error {
 	//<-ch用来从channel ch中接受数据，这个表达式会一直阻塞，直到有数据可以接受
-	call := <-client.Go(serviceMethod, args, reply, make(chan *Call, 1)).Done
+	call := <-client.GoContext(ctx, serviceMethod, args, reply, make(chan *Call, 1)).Done
 	return call.Error
 }
@@ -0,0 +1,69 @@
+package YARPC
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+//pendingLen返回client中尚未结束的call的个数
+func pendingLen(client *Client) int {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	return len(client.pending)
+}
+
+//dialEcho连接addr，并在测试结束时关闭连接
+func dialEcho(t *testing.T, addr string, opts ...*Option) *Client {
+	t.Helper()
+	client, err := Dial("tcp", addr, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+func TestCallContextRemovesPending(t *testing.T) {
+	svc := newEchoService()
+	defer close(svc.release)
+	client := dialEcho(t, startServer(t, NewServer(), svc))
+
+	t.Run("deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		var reply int
+		if err := client.CallContext(ctx, "Echo.Block", 1, &reply); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("err = %v, want %v", err, context.DeadlineExceeded)
+		}
+		if n := pendingLen(client); n != 0 {
+			t.Fatalf("len(pending) = %d, want 0", n)
+		}
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		var reply int
+		call := client.GoContext(ctx, "Echo.Block", 1, &reply, nil)
+		<-svc.started
+		cancel()
+		select {
+		case <-call.Done:
+		case <-time.After(time.Second):
+			t.Fatal("call not finished after cancel")
+		}
+		if !errors.Is(call.Error, context.Canceled) {
+			t.Fatalf("err = %v, want %v", call.Error, context.Canceled)
+		}
+		if n := pendingLen(client); n != 0 {
+			t.Fatalf("len(pending) = %d, want 0", n)
+		}
+	})
+
+	//服务端稍后发送的被放弃的回复不应影响之后的调用
+	var reply int
+	if err := client.Call("Echo.Double", 21, &reply); err != nil || reply != 42 {
+		t.Fatalf("Call = %d, %v; want 42, nil", reply, err)
+	}
+}
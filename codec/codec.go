@@ -1,11 +1,23 @@
 package codec
 
-import "io"
+import (
+	"errors"
+	"io"
+	"reflect"
+	"sync"
+	"time"
+)
 
 type Header struct {
-	ServiceMethod string //格式为“Service.Method"
-	Seq           uint64 //一个RPC请求的ID，由客户端指定
-	Error         string //错误信息，客户端置为空，服务端如果发生错误，将错误信息置于Error中
+	ServiceMethod string        //格式为“Service.Method"
+	Seq           uint64        //一个RPC请求的ID，由客户端指定
+	Error         string        //错误信息，客户端置为空，服务端如果发生错误，将错误信息置于Error中
+	Timeout       time.Duration //客户端剩余的等待时间，0表示不设限；旧版本的对端不发送该字段，解码后即为0
+	//请求中为客户端设置的元数据，回复中为服务端设置的trailer，可以为nil
+	Metadata map[string]string
+	//错误的类别和附加信息，只在Error不为空时有意义；旧版本的对端不发送这两个字段，解码后即为零值
+	ErrorCode    uint32
+	ErrorDetails []string
 }
 type Codec interface {
 	io.Closer
@@ -15,27 +27,77 @@ type Codec interface {
 }
 type NewCodecFunc func(io.ReadWriteCloser) Codec
 
+//BodyChecker是Codec可以选择实现的接口，服务端在调用方法之前通过CheckBody检查reply的类型能否被编码，
+//返回错误时不再调用方法，而是直接回复该错误，例如ProtoCodec只能编码proto.Message
+type BodyChecker interface {
+	CheckBody(t reflect.Type) error
+}
+
 type Type string
 
 const (
-	GobType Type = "application/gob"
+	GobType      Type = "application/gob"
+	JsonType     Type = "application/json"
+	ProtobufType Type = "application/protobuf"
+	MsgpackType  Type = "application/msgpack"
 )
 
+//codecsMu保护NewCodecFuncMap，Register和Lookup都需要持有它
+var codecsMu sync.RWMutex
+
 //map的定义方式为 var 名称 map[keytype]valuetype
 //用一个map存储不同类型的codec的构造函数
-var NewCodecFuncMap map[Type]NewCodecFunc
+//
+//Deprecated: 直接读写NewCodecFuncMap不是并发安全的，请使用Register和Lookup，
+//保留它只是为了兼容直接读写该map的第三方代码
+var NewCodecFuncMap = make(map[Type]NewCodecFunc)
+
+//Register注册一种codec，可以被其他包(例如独立模块中的msgpack、protobuf codec)在init中调用
+//同一个Type只能注册一次，重复注册会返回错误
+func Register(t Type, f NewCodecFunc) error {
+	if t == "" {
+		return errors.New("rpc codec: register codec with empty type")
+	}
+	if f == nil {
+		return errors.New("rpc codec: register nil NewCodecFunc for " + string(t))
+	}
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	if _, dup := NewCodecFuncMap[t]; dup {
+		return errors.New("rpc codec: codec already registered: " + string(t))
+	}
+	NewCodecFuncMap[t] = f
+	return nil
+}
+
+//Lookup返回t对应的codec构造函数，ok为false表示该codec没有被注册
+func Lookup(t Type) (f NewCodecFunc, ok bool) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	f, ok = NewCodecFuncMap[t]
+	return
+}
 
 /*
-	init()函数会先于main函数自动执行
-	init函数没有输入参数、返回值，也未声明，所以无法引用
+init()函数会先于main函数自动执行
+init函数没有输入参数、返回值，也未声明，所以无法引用
+
 	无论包被导入多少次，init函数只会被调用一次，也就是只执行一次
 */
 func init() {
-	/*
-		使用make对map类型的变量进行初始化
-		make用来分配并且初始化一个slice,map或者chan对象，并且只能是这三种对象。
-		make的返回值就是这个类型，而不是指针
-	*/
-	NewCodecFuncMap = make(map[Type]NewCodecFunc)
-	NewCodecFuncMap[GobType] = NewGobCodec
+	//内置的codec同样通过Register注册，与第三方codec使用同一条经过校验的路径
+	builtin := []struct {
+		t Type
+		f NewCodecFunc
+	}{
+		{GobType, NewGobCodec},
+		{JsonType, NewJSONCodec},
+		{ProtobufType, NewProtoCodec},
+		{MsgpackType, NewMsgpackCodec},
+	}
+	for _, c := range builtin {
+		if err := Register(c.t, c.f); err != nil {
+			panic(err)
+		}
+	}
 }
@@ -0,0 +1,60 @@
+package codec
+
+import (
+	"io"
+	"testing"
+)
+
+func TestRegister(t *testing.T) {
+	if err := Register(GobType, NewGobCodec); err == nil {
+		t.Error("Register(GobType) succeeded, want duplicate error")
+	}
+	if err := Register("", NewGobCodec); err == nil {
+		t.Error(`Register("") succeeded, want error`)
+	}
+	if err := Register("application/x-test-nil", nil); err == nil {
+		t.Error("Register(nil) succeeded, want error")
+	}
+	if _, ok := Lookup("application/x-test-nil"); ok {
+		t.Error("rejected codec is registered")
+	}
+
+	const testType Type = "application/x-test"
+	if _, ok := Lookup(testType); ok {
+		t.Fatalf("Lookup(%s) found a codec before Register", testType)
+	}
+	var called bool
+	f := func(conn io.ReadWriteCloser) Codec {
+		called = true
+		return NewGobCodec(conn)
+	}
+	if err := Register(testType, f); err != nil {
+		t.Fatal(err)
+	}
+	//注册表是全局的，测试结束后移除testType，使测试可以重复运行(-count)
+	t.Cleanup(func() {
+		codecsMu.Lock()
+		defer codecsMu.Unlock()
+		delete(NewCodecFuncMap, testType)
+	})
+	got, ok := Lookup(testType)
+	if !ok {
+		t.Fatalf("Lookup(%s) not found after Register", testType)
+	}
+	_ = got(nopConn{})
+	if !called {
+		t.Fatal("Lookup returned a different NewCodecFunc")
+	}
+	for _, typ := range []Type{GobType, JsonType, ProtobufType, MsgpackType} {
+		if _, ok := Lookup(typ); !ok {
+			t.Errorf("built-in codec %s not registered", typ)
+		}
+	}
+}
+
+//nopConn是一个什么也不做的连接，只用于构造codec
+type nopConn struct{}
+
+func (nopConn) Read([]byte) (int, error)    { return 0, io.EOF }
+func (nopConn) Write(p []byte) (int, error) { return len(p), nil }
+func (nopConn) Close() error                { return nil }
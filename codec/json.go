@@ -0,0 +1,63 @@
+package codec
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"log"
+)
+
+//JSONCodec与GobCodec类似，在同一个连接上依次传输Header和Body，每个部分都是一个独立的JSON值
+type JSONCodec struct {
+	conn io.ReadWriteCloser
+	buf  *bufio.Writer
+	dec  *json.Decoder
+	enc  *json.Encoder
+}
+
+var _ Codec = (*JSONCodec)(nil)
+
+func NewJSONCodec(conn io.ReadWriteCloser) Codec {
+	buf := bufio.NewWriter(conn)
+	return &JSONCodec{
+		conn: conn,
+		buf:  buf,
+		dec:  json.NewDecoder(conn),
+		enc:  json.NewEncoder(buf),
+	}
+}
+
+func (c *JSONCodec) ReadHeader(h *Header) error {
+	return c.dec.Decode(h)
+}
+
+func (c *JSONCodec) ReadBody(body interface{}) error {
+	//json.Decoder不接受nil，需要丢弃的Body解码到json.RawMessage中
+	if body == nil {
+		var discard json.RawMessage
+		return c.dec.Decode(&discard)
+	}
+	return c.dec.Decode(body)
+}
+
+func (c *JSONCodec) Write(h *Header, body interface{}) (err error) {
+	defer func() {
+		_ = c.buf.Flush()
+		if err != nil {
+			_ = c.Close()
+		}
+	}()
+	if err := c.enc.Encode(h); err != nil {
+		log.Println("rpc codec: json error encoding header:", err)
+		return err
+	}
+	if err := c.enc.Encode(body); err != nil {
+		log.Println("rpc codec: json error encoding body:", err)
+		return err
+	}
+	return nil
+}
+
+func (c *JSONCodec) Close() error {
+	return c.conn.Close()
+}
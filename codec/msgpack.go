@@ -0,0 +1,64 @@
+package codec
+
+import (
+	"bufio"
+	"io"
+	"log"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+//MsgpackCodec与GobCodec类似，在同一个连接上依次传输Header和Body，每个部分都是一个独立的msgpack值
+//Body可以是值类型或指针类型，解码时可以直接写入newReplyv预先创建好的map和slice
+type MsgpackCodec struct {
+	conn io.ReadWriteCloser
+	buf  *bufio.Writer
+	dec  *msgpack.Decoder
+	enc  *msgpack.Encoder
+}
+
+var _ Codec = (*MsgpackCodec)(nil)
+
+func NewMsgpackCodec(conn io.ReadWriteCloser) Codec {
+	buf := bufio.NewWriter(conn)
+	return &MsgpackCodec{
+		conn: conn,
+		buf:  buf,
+		dec:  msgpack.NewDecoder(bufio.NewReader(conn)),
+		enc:  msgpack.NewEncoder(buf),
+	}
+}
+
+func (c *MsgpackCodec) ReadHeader(h *Header) error {
+	return c.dec.Decode(h)
+}
+
+func (c *MsgpackCodec) ReadBody(body interface{}) error {
+	//body为nil时跳过这个值
+	if body == nil {
+		return c.dec.Skip()
+	}
+	return c.dec.Decode(body)
+}
+
+func (c *MsgpackCodec) Write(h *Header, body interface{}) (err error) {
+	defer func() {
+		_ = c.buf.Flush()
+		if err != nil {
+			_ = c.Close()
+		}
+	}()
+	if err := c.enc.Encode(h); err != nil {
+		log.Println("rpc codec: msgpack error encoding header:", err)
+		return err
+	}
+	if err := c.enc.Encode(body); err != nil {
+		log.Println("rpc codec: msgpack error encoding body:", err)
+		return err
+	}
+	return nil
+}
+
+func (c *MsgpackCodec) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,278 @@
+package codec
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"reflect"
+	"sort"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+//单个帧允许的最大长度，防止错误的长度前缀导致分配过多内存
+const maxProtoFrameSize = 64 << 20
+
+//Header在ProtoCodec中按照下面的.proto定义编码，其他语言可以据此生成对应的代码
+//	message Header {
+//		string service_method = 1;
+//		uint64 seq = 2;
+//		string error = 3;
+//		int64 timeout = 4; //纳秒
+//		map<string, string> metadata = 5;
+//		uint32 error_code = 6;
+//		repeated string error_details = 7;
+//	}
+const (
+	protoHeaderServiceMethod protowire.Number = 1
+	protoHeaderSeq           protowire.Number = 2
+	protoHeaderError         protowire.Number = 3
+	protoHeaderTimeout       protowire.Number = 4
+	protoHeaderMetadata      protowire.Number = 5
+	protoHeaderErrorCode     protowire.Number = 6
+	protoHeaderErrorDetails  protowire.Number = 7
+)
+
+//ProtoCodec将Header和Body分别编码为protobuf的二进制格式，每一帧之前是一个uvarint表示的长度前缀
+//即与protobuf的delimited格式(如Java的writeDelimitedTo)兼容
+//Body必须实现proto.Message
+type ProtoCodec struct {
+	conn io.ReadWriteCloser
+	buf  *bufio.Writer
+	r    *bufio.Reader
+}
+
+var (
+	_ Codec       = (*ProtoCodec)(nil)
+	_ BodyChecker = (*ProtoCodec)(nil)
+)
+
+var typeOfProtoMessage = reflect.TypeOf((*proto.Message)(nil)).Elem()
+
+//CheckBody检查t是否实现了proto.Message
+func (c *ProtoCodec) CheckBody(t reflect.Type) error {
+	if !t.Implements(typeOfProtoMessage) {
+		return fmt.Errorf("rpc codec: proto: %s is not a proto.Message", t)
+	}
+	return nil
+}
+
+func NewProtoCodec(conn io.ReadWriteCloser) Codec {
+	return &ProtoCodec{
+		conn: conn,
+		buf:  bufio.NewWriter(conn),
+		r:    bufio.NewReader(conn),
+	}
+}
+
+//readFrame读取一个带长度前缀的帧
+func (c *ProtoCodec) readFrame() ([]byte, error) {
+	size, err := binary.ReadUvarint(c.r)
+	if err != nil {
+		return nil, err
+	}
+	if size > maxProtoFrameSize {
+		return nil, fmt.Errorf("rpc codec: proto frame too large: %d bytes", size)
+	}
+	frame := make([]byte, size)
+	if _, err := io.ReadFull(c.r, frame); err != nil {
+		return nil, err
+	}
+	return frame, nil
+}
+
+func (c *ProtoCodec) writeFrame(frame []byte) error {
+	if _, err := c.buf.Write(protowire.AppendVarint(nil, uint64(len(frame)))); err != nil {
+		return err
+	}
+	_, err := c.buf.Write(frame)
+	return err
+}
+
+func (c *ProtoCodec) ReadHeader(h *Header) error {
+	frame, err := c.readFrame()
+	if err != nil {
+		return err
+	}
+	*h = Header{}
+	for len(frame) > 0 {
+		num, typ, n := protowire.ConsumeTag(frame)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		frame = frame[n:]
+		switch {
+		case num == protoHeaderServiceMethod && typ == protowire.BytesType:
+			var v string
+			v, n = protowire.ConsumeString(frame)
+			h.ServiceMethod = v
+		case num == protoHeaderSeq && typ == protowire.VarintType:
+			var v uint64
+			v, n = protowire.ConsumeVarint(frame)
+			h.Seq = v
+		case num == protoHeaderError && typ == protowire.BytesType:
+			var v string
+			v, n = protowire.ConsumeString(frame)
+			h.Error = v
+		case num == protoHeaderTimeout && typ == protowire.VarintType:
+			var v uint64
+			v, n = protowire.ConsumeVarint(frame)
+			h.Timeout = time.Duration(int64(v))
+		case num == protoHeaderMetadata && typ == protowire.BytesType:
+			var entry []byte
+			entry, n = protowire.ConsumeBytes(frame)
+			if n >= 0 {
+				if err := decodeProtoMetadataEntry(h, entry); err != nil {
+					return err
+				}
+			}
+		case num == protoHeaderErrorCode && typ == protowire.VarintType:
+			var v uint64
+			v, n = protowire.ConsumeVarint(frame)
+			h.ErrorCode = uint32(v)
+		case num == protoHeaderErrorDetails && typ == protowire.BytesType:
+			var v string
+			v, n = protowire.ConsumeString(frame)
+			h.ErrorDetails = append(h.ErrorDetails, v)
+		default:
+			//跳过不认识的字段，以兼容将来新增的字段
+			n = protowire.ConsumeFieldValue(num, typ, frame)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		frame = frame[n:]
+	}
+	return nil
+}
+
+func (c *ProtoCodec) ReadBody(body interface{}) error {
+	frame, err := c.readFrame()
+	if err != nil {
+		return err
+	}
+	//body为nil时表示丢弃这个Body
+	if body == nil {
+		return nil
+	}
+	m, ok := body.(proto.Message)
+	if !ok {
+		return fmt.Errorf("rpc codec: proto: body of type %T is not a proto.Message", body)
+	}
+	return proto.Unmarshal(frame, m)
+}
+
+func (c *ProtoCodec) Write(h *Header, body interface{}) error {
+	//在写入任何内容之前检查body，类型不符时不写出不完整的报文，也不关闭连接
+	var b []byte
+	if m, ok := body.(proto.Message); ok {
+		var err error
+		if b, err = proto.Marshal(m); err != nil {
+			log.Println("rpc codec: proto error encoding body:", err)
+			return err
+		}
+	} else if h.Error == "" {
+		//服务端回复错误时Body只是一个占位符，此时写入一个空的Body，其他情况下Body必须是proto.Message
+		return fmt.Errorf("rpc codec: proto: body of type %T is not a proto.Message", body)
+	}
+	return c.write(h, b)
+}
+
+func (c *ProtoCodec) write(h *Header, body []byte) (err error) {
+	defer func() {
+		_ = c.buf.Flush()
+		if err != nil {
+			_ = c.Close()
+		}
+	}()
+	if err := c.writeFrame(encodeProtoHeader(h)); err != nil {
+		log.Println("rpc codec: proto error encoding header:", err)
+		return err
+	}
+	if err := c.writeFrame(body); err != nil {
+		log.Println("rpc codec: proto error encoding body:", err)
+		return err
+	}
+	return nil
+}
+
+func (c *ProtoCodec) Close() error {
+	return c.conn.Close()
+}
+
+func encodeProtoHeader(h *Header) []byte {
+	var b []byte
+	if h.ServiceMethod != "" {
+		b = protowire.AppendTag(b, protoHeaderServiceMethod, protowire.BytesType)
+		b = protowire.AppendString(b, h.ServiceMethod)
+	}
+	if h.Seq != 0 {
+		b = protowire.AppendTag(b, protoHeaderSeq, protowire.VarintType)
+		b = protowire.AppendVarint(b, h.Seq)
+	}
+	if h.Error != "" {
+		b = protowire.AppendTag(b, protoHeaderError, protowire.BytesType)
+		b = protowire.AppendString(b, h.Error)
+	}
+	if h.Timeout != 0 {
+		b = protowire.AppendTag(b, protoHeaderTimeout, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(h.Timeout))
+	}
+	//map在protobuf中被编码为重复的entry消息，为了使编码结果确定，按照键排序
+	keys := make([]string, 0, len(h.Metadata))
+	for k := range h.Metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		var entry []byte
+		entry = protowire.AppendTag(entry, 1, protowire.BytesType)
+		entry = protowire.AppendString(entry, k)
+		entry = protowire.AppendTag(entry, 2, protowire.BytesType)
+		entry = protowire.AppendString(entry, h.Metadata[k])
+		b = protowire.AppendTag(b, protoHeaderMetadata, protowire.BytesType)
+		b = protowire.AppendBytes(b, entry)
+	}
+	if h.ErrorCode != 0 {
+		b = protowire.AppendTag(b, protoHeaderErrorCode, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(h.ErrorCode))
+	}
+	for _, d := range h.ErrorDetails {
+		b = protowire.AppendTag(b, protoHeaderErrorDetails, protowire.BytesType)
+		b = protowire.AppendString(b, d)
+	}
+	return b
+}
+
+//decodeProtoMetadataEntry解析map<string, string>中的一个entry消息，并将其加入h.Metadata
+func decodeProtoMetadataEntry(h *Header, entry []byte) error {
+	var key, value string
+	for len(entry) > 0 {
+		num, typ, n := protowire.ConsumeTag(entry)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		entry = entry[n:]
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			key, n = protowire.ConsumeString(entry)
+		case num == 2 && typ == protowire.BytesType:
+			value, n = protowire.ConsumeString(entry)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, entry)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		entry = entry[n:]
+	}
+	if h.Metadata == nil {
+		h.Metadata = make(map[string]string)
+	}
+	h.Metadata[key] = value
+	return nil
+}
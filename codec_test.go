@@ -0,0 +1,62 @@
+package YARPC
+
+import (
+	"YARPC/codec"
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+//testCodecs是需要通过完整的RPC调用测试的codec
+var testCodecs = []codec.Type{codec.GobType, codec.JsonType, codec.ProtobufType, codec.MsgpackType}
+
+//forEachCodec对每一种codec建立一个连接到addr的Client，并运行f
+func forEachCodec(t *testing.T, addr string, f func(t *testing.T, typ codec.Type, client *Client)) {
+	for _, typ := range testCodecs {
+		typ := typ
+		t.Run(string(typ), func(t *testing.T) {
+			f(t, typ, dialEcho(t, addr, &Option{CodecType: typ}))
+		})
+	}
+}
+
+func TestCodecsRoundTrip(t *testing.T) {
+	forEachCodec(t, startServer(t, NewServer(), newEchoService()), func(t *testing.T, typ codec.Type, client *Client) {
+		var reply wrapperspb.StringValue
+		if err := client.Call("Echo.Upper", wrapperspb.String("abc"), &reply); err != nil {
+			t.Fatal(err)
+		}
+		if reply.Value != "ABC" {
+			t.Fatalf("reply = %q, want %q", reply.Value, "ABC")
+		}
+		//值类型的参数和返回值，ProtoCodec只支持proto.Message
+		if typ == codec.ProtobufType {
+			return
+		}
+		var n int
+		if err := client.Call("Echo.Double", 21, &n); err != nil || n != 42 {
+			t.Fatalf("Double = %d, %v; want 42, nil", n, err)
+		}
+	})
+}
+
+func TestProtoRejectsNonMessageReply(t *testing.T) {
+	client := dialEcho(t, startServer(t, NewServer(), newEchoService()), &Option{CodecType: codec.ProtobufType})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var n int
+	if err := client.CallContext(ctx, "Echo.Len", wrapperspb.String("abc"), &n); StatusCode(err) != InvalidArgument {
+		t.Fatalf("Len err = %v, want an InvalidArgument error", err)
+	}
+	//reply编码失败时回复Internal错误，而不是让客户端一直等待
+	var reply wrapperspb.StringValue
+	if err := client.CallContext(ctx, "Echo.InvalidUTF8", wrapperspb.String("abc"), &reply); StatusCode(err) != Internal {
+		t.Fatalf("InvalidUTF8 err = %v, want an Internal error", err)
+	}
+	//之后的调用不受影响
+	if err := client.CallContext(ctx, "Echo.Upper", wrapperspb.String("abc"), &reply); err != nil || reply.Value != "ABC" {
+		t.Fatalf("Upper = %q, %v; want %q, nil", reply.Value, err, "ABC")
+	}
+}
@@ -0,0 +1,64 @@
+package YARPC
+
+import (
+	"html/template"
+	"net/http"
+	"sort"
+)
+
+//调试页面的模板，列出每个service的所有方法，以及方法的参数类型、返回值类型、被调用的次数和出错的次数
+const debugText = `<html>
+	<body>
+	<title>YARPC Services</title>
+	{{range .}}
+	<hr>
+	Service {{.Name}}
+	<hr>
+		<table>
+		<th align=center>Method</th><th align=center>Calls</th><th align=center>Errors</th>
+		{{range $name, $mtype := .Method}}
+			<tr>
+			<td align=left font=fixed>{{$name}}({{$mtype.ArgType}}, {{$mtype.ReplyType}}) error</td>
+			<td align=center>{{$mtype.NumCalls}}</td>
+			<td align=center>{{$mtype.NumErrors}}</td>
+			</tr>
+		{{end}}
+		</table>
+	{{end}}
+	</body>
+	</html>`
+
+var debugTemplate = template.Must(template.New("RPC debug").Parse(debugText))
+
+type debugHTTP struct {
+	*Server
+}
+
+type debugService struct {
+	Name   string
+	Method map[string]*methodType
+}
+
+//DebugHandler返回展示server调试页面的http.Handler，
+//在自己的http.ServeMux上提供服务时可以通过mux.Handle("/debug/yarpc", server.DebugHandler())挂载
+func (server *Server) DebugHandler() http.Handler {
+	return debugHTTP{server}
+}
+
+//ServeHTTP遍历serviceMap，按照service的名称排序后渲染调试页面
+func (server debugHTTP) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var services []debugService
+	server.serviceMap.Range(func(namei, svci interface{}) bool {
+		svc := svci.(*service)
+		services = append(services, debugService{
+			Name:   namei.(string),
+			Method: svc.method,
+		})
+		return true
+	})
+	sort.Slice(services, func(i, j int) bool { return services[i].Name < services[j].Name })
+	err := debugTemplate.Execute(w, services)
+	if err != nil {
+		_, _ = w.Write([]byte("rpc: error executing template: " + err.Error()))
+	}
+}
@@ -0,0 +1,99 @@
+package YARPC
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+//startHTTPServer在自己的http.ServeMux上提供server，返回其地址
+func startHTTPServer(t *testing.T, server *Server) string {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.Handle(defaultRPCPath, server)
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+	return ts.Listener.Addr().String()
+}
+
+func TestDialHTTP(t *testing.T) {
+	server := NewServer()
+	if err := server.RegisterName("Echo", newEchoService()); err != nil {
+		t.Fatal(err)
+	}
+	addr := startHTTPServer(t, server)
+
+	for _, dial := range []struct {
+		name string
+		f    func() (*Client, error)
+	}{
+		{"DialHTTP", func() (*Client, error) { return DialHTTP("tcp", addr) }},
+		{"XDial", func() (*Client, error) { return XDial("http@" + addr) }},
+	} {
+		t.Run(dial.name, func(t *testing.T) {
+			client, err := dial.f()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = client.Close() }()
+			var reply int
+			if err := client.Call("Echo.Double", 21, &reply); err != nil || reply != 42 {
+				t.Fatalf("Call = %d, %v; want 42, nil", reply, err)
+			}
+		})
+	}
+
+	//只接受CONNECT请求
+	resp, err := http.Get("http://" + addr + defaultRPCPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("GET status = %d, want %d", resp.StatusCode, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestDebugHandler(t *testing.T) {
+	server := NewServer()
+	client := dialEcho(t, startServer(t, server, newEchoService()))
+	var n int
+	for i := 0; i < 2; i++ {
+		if err := client.Call("Echo.Double", i, &n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var reply wrapperspb.StringValue
+	_ = client.Call("Echo.Fail", wrapperspb.String("x"), &reply)
+
+	//DebugHandler可以挂载在任意的mux和路径上
+	mux := http.NewServeMux()
+	mux.Handle("/debug", server.DebugHandler())
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	resp, err := http.Get(ts.URL + "/debug")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	page := string(body)
+	for _, pattern := range []string{
+		`Service Echo`,
+		//方法名、参数类型、返回值类型、调用次数和出错次数
+		`Double\(int, \*int\) error</td>\s*<td align=center>2</td>\s*<td align=center>0</td>`,
+		`Fail\(\*wrapperspb.StringValue, \*wrapperspb.StringValue\) error</td>\s*<td align=center>1</td>\s*<td align=center>1</td>`,
+		`Block\(int, \*int\) error</td>\s*<td align=center>0</td>`,
+	} {
+		if !regexp.MustCompile(pattern).MatchString(page) {
+			t.Errorf("debug page does not match %s:\n%s", pattern, page)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package YARPC
+
+import (
+	"YARPC/codec"
+	"context"
+	"reflect"
+)
+
+//ServerInfo描述一次被拦截的请求
+type ServerInfo struct {
+	Header      *codec.Header //请求的Header
+	ServiceName string        //服务名，即ServiceMethod中最后一个"."之前的部分
+	MethodName  string        //方法名
+}
+
+//ServerHandler完成一次方法调用，argv是指向请求参数的指针(参数本身是指针时即为该指针)，replyv是指向返回值的指针
+//拦截器可以通过argv修改方法收到的参数，但传给next的argv和replyv本身会被忽略，方法总是使用请求自己的参数和返回值
+type ServerHandler func(ctx context.Context, info *ServerInfo, argv, replyv interface{}) error
+
+//ServerInterceptor包裹从readRequest得到请求到service.call完成调用的过程
+//拦截器可以调用next继续处理请求，也可以直接返回一个错误，此时该错误会被写入Header.Error
+type ServerInterceptor func(ctx context.Context, info *ServerInfo, argv, replyv interface{}, next ServerHandler) error
+
+//Use添加一个服务端拦截器，先添加的拦截器位于调用链的外层
+func (server *Server) Use(interceptor ServerInterceptor) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	server.interceptors = append(server.interceptors, interceptor)
+}
+
+//为DefaultServer设置的Use方法
+func Use(interceptor ServerInterceptor) { DefaultServer.Use(interceptor) }
+
+//invoke依次经过所有的拦截器，最终通过req.svc.call完成方法调用
+func (server *Server) invoke(req *request) error {
+	server.mu.Lock()
+	interceptors := server.interceptors
+	server.mu.Unlock()
+
+	handler := func(ctx context.Context, _ *ServerInfo, _, _ interface{}) error {
+		return req.svc.call(ctx, req.mtype, req.argv, req.replyv)
+	}
+	if len(interceptors) == 0 {
+		return handler(req.ctx, nil, nil, nil)
+	}
+	//从内向外包裹，使interceptors[0]位于最外层
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], handler
+		handler = func(ctx context.Context, info *ServerInfo, argv, replyv interface{}) error {
+			return interceptor(ctx, info, argv, replyv, next)
+		}
+	}
+	info := &ServerInfo{
+		Header:      req.h,
+		ServiceName: req.svc.name,
+		MethodName:  req.mtype.method.Name,
+	}
+	//值类型的参数也以指针的形式传给拦截器，使拦截器对参数的修改(例如规范化)对方法可见
+	argv := req.argv.Interface()
+	if req.argv.Kind() != reflect.Ptr {
+		argv = req.argv.Addr().Interface()
+	}
+	return handler(req.ctx, info, argv, req.replyv.Interface())
+}
+
+//ClientHandler发送一次调用并等待其完成，返回call的错误
+type ClientHandler func(ctx context.Context, call *Call) error
+
+//ClientInterceptor包裹Client.Go/Client.Call，可以在调用next之前查看或修改call.ServiceMethod、call.Args、call.Reply和call.Metadata，
+//并通过next的返回值得到最终的call.Error，例如实现重试、链路追踪和指标统计
+//拦截器可以多次调用next，每次都会作为一个新的请求发送
+type ClientInterceptor func(ctx context.Context, call *Call, next ClientHandler) error
+
+//Use添加一个客户端拦截器，先添加的拦截器位于调用链的外层
+func (client *Client) Use(interceptor ClientInterceptor) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.interceptors = append(client.interceptors, interceptor)
+}
+
+//invoke是客户端调用链的最内层，按照call当前的内容发送一个新的请求并等待其完成
+func (client *Client) invoke(ctx context.Context, call *Call) error {
+	inner := &Call{
+		ServiceMethod: call.ServiceMethod,
+		Args:          call.Args,
+		Reply:         call.Reply,
+		Done:          make(chan *Call, 1),
+		Metadata:      call.Metadata,
+		finished:      make(chan struct{}),
+	}
+	client.start(ctx, inner)
+	inner = <-inner.Done
+	call.Seq = inner.Seq
+	call.Trailer = inner.Trailer
+	return inner.Error
+}
+
+//chainClientInterceptors从内向外包裹handler，使interceptors[0]位于最外层
+func chainClientInterceptors(interceptors []ClientInterceptor, handler ClientHandler) ClientHandler {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], handler
+		handler = func(ctx context.Context, call *Call) error {
+			return interceptor(ctx, call, next)
+		}
+	}
+	return handler
+}
@@ -0,0 +1,141 @@
+package YARPC
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+//recorder按顺序记录拦截器的执行过程，可以被并发使用
+type recorder struct {
+	mu   sync.Mutex
+	logs []string
+}
+
+func (r *recorder) add(s string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.logs = append(r.logs, s)
+}
+
+func (r *recorder) get() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.logs...)
+}
+
+func TestServerInterceptorOrder(t *testing.T) {
+	server := NewServer()
+	var rec recorder
+	for _, name := range []string{"1", "2"} {
+		name := name
+		server.Use(func(ctx context.Context, info *ServerInfo, argv, replyv interface{}, next ServerHandler) error {
+			rec.add(name + " " + info.ServiceName + "." + info.MethodName)
+			err := next(ctx, info, argv, replyv)
+			rec.add(name + " done")
+			return err
+		})
+	}
+	client := dialEcho(t, startServer(t, server, newEchoService()))
+	var reply int
+	if err := client.Call("Echo.Double", 21, &reply); err != nil || reply != 42 {
+		t.Fatalf("Call = %d, %v; want 42, nil", reply, err)
+	}
+	//interceptors[0]位于最外层
+	want := []string{"1 Echo.Double", "2 Echo.Double", "2 done", "1 done"}
+	if got := rec.get(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("order = %v, want %v", got, want)
+	}
+}
+
+func TestServerInterceptorShortCircuit(t *testing.T) {
+	server := NewServer()
+	server.Use(func(ctx context.Context, info *ServerInfo, argv, replyv interface{}, next ServerHandler) error {
+		return NewStatus(PermissionDenied, "denied")
+	})
+	client := dialEcho(t, startServer(t, server, newEchoService()))
+	var reply int
+	err := client.Call("Echo.Double", 21, &reply)
+	if StatusCode(err) != PermissionDenied || err.Error() != "denied" {
+		t.Fatalf("err = %v, want PermissionDenied denied", err)
+	}
+	_, mtype, _ := server.findService("Echo.Double")
+	if n := mtype.NumCalls(); n != 0 {
+		t.Fatalf("method called %d times, want 0", n)
+	}
+}
+
+func TestServerInterceptorModifiesArgs(t *testing.T) {
+	server := NewServer()
+	server.Use(func(ctx context.Context, info *ServerInfo, argv, replyv interface{}, next ServerHandler) error {
+		//Double的参数是值类型int，拦截器收到的是*int
+		*argv.(*int) += 1
+		if err := next(ctx, info, argv, replyv); err != nil {
+			return err
+		}
+		*replyv.(*int) *= 10
+		return nil
+	})
+	client := dialEcho(t, startServer(t, server, newEchoService()))
+	var reply int
+	if err := client.Call("Echo.Double", 20, &reply); err != nil || reply != 420 {
+		t.Fatalf("Call = %d, %v; want 420, nil", reply, err)
+	}
+}
+
+func TestClientInterceptorRewritesCall(t *testing.T) {
+	server := NewServer()
+	//在服务端记录收到的元数据
+	mds := make(chan map[string]string, 1)
+	server.Use(func(ctx context.Context, info *ServerInfo, argv, replyv interface{}, next ServerHandler) error {
+		md, _ := MetadataFromContext(ctx)
+		mds <- md
+		return next(ctx, info, argv, replyv)
+	})
+	client := dialEcho(t, startServer(t, server, newEchoService()))
+	client.Use(func(ctx context.Context, call *Call, next ClientHandler) error {
+		call.ServiceMethod = "Echo.Double"
+		call.Args = call.Args.(int) + 1
+		call.Metadata["trace-id"] = "abc"
+		return next(ctx, call)
+	})
+	var reply int
+	if err := client.Call("Echo.Missing", 20, &reply); err != nil || reply != 42 {
+		t.Fatalf("Call = %d, %v; want 42, nil", reply, err)
+	}
+	if md := <-mds; md["trace-id"] != "abc" {
+		t.Fatalf("server metadata = %v, want trace-id=abc", md)
+	}
+}
+
+func TestClientInterceptorRetry(t *testing.T) {
+	client := dialEcho(t, startServer(t, NewServer(), newEchoService()))
+	var attempts int
+	var final error
+	//外层拦截器看到重试之后的最终错误
+	client.Use(func(ctx context.Context, call *Call, next ClientHandler) error {
+		final = next(ctx, call)
+		return final
+	})
+	client.Use(func(ctx context.Context, call *Call, next ClientHandler) error {
+		var err error
+		for attempts < 3 {
+			attempts++
+			if err = next(ctx, call); StatusCode(err) != NotFound {
+				break
+			}
+		}
+		return err
+	})
+	reply := &wrapperspb.StringValue{}
+	err := client.Call("Echo.Fail", wrapperspb.String("x"), reply)
+	if attempts != 3 {
+		t.Fatalf("attempts = %d, want 3", attempts)
+	}
+	if StatusCode(err) != NotFound || err != final {
+		t.Fatalf("err = %v, final = %v; want the same NotFound error", err, final)
+	}
+}
@@ -0,0 +1,80 @@
+package YARPC
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+//元数据是随请求一起传递的键值对，保存在codec.Header.Metadata中，例如鉴权token、租户ID、trace ID等
+//客户端通过WithMetadata或Call.Metadata为每次调用设置元数据，服务端的方法和拦截器通过MetadataFromContext读取
+//服务端还可以通过SetTrailer设置随回复返回的元数据，客户端从Call.Trailer中读取
+
+type outgoingMetadataKey struct{}
+type incomingMetadataKey struct{}
+type trailerKey struct{}
+
+//WithMetadata返回一个携带md的ctx，使用该ctx的CallContext/GoContext会将md发送给服务端
+//多次调用时后设置的键覆盖先设置的键
+func WithMetadata(ctx context.Context, md map[string]string) context.Context {
+	merged := copyMetadata(outgoingMetadata(ctx))
+	if merged == nil {
+		merged = make(map[string]string, len(md))
+	}
+	for k, v := range md {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, outgoingMetadataKey{}, merged)
+}
+
+func outgoingMetadata(ctx context.Context) map[string]string {
+	md, _ := ctx.Value(outgoingMetadataKey{}).(map[string]string)
+	return md
+}
+
+//MetadataFromContext在服务端返回请求携带的元数据，返回的map是一个副本
+func MetadataFromContext(ctx context.Context) (map[string]string, bool) {
+	md, ok := ctx.Value(incomingMetadataKey{}).(map[string]string)
+	if !ok {
+		return nil, false
+	}
+	return copyMetadata(md), true
+}
+
+//trailer保存服务端设置的回复元数据，方法超时后仍可能被并发写入，因此需要加锁
+type trailer struct {
+	mu sync.Mutex
+	md map[string]string
+}
+
+func (t *trailer) get() map[string]string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return copyMetadata(t.md)
+}
+
+//SetTrailer在服务端设置随回复一起返回给客户端的元数据，ctx必须是请求的ctx
+func SetTrailer(ctx context.Context, key, value string) error {
+	t, ok := ctx.Value(trailerKey{}).(*trailer)
+	if !ok {
+		return errors.New("rpc server: SetTrailer called with a context not created by the server")
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.md == nil {
+		t.md = make(map[string]string)
+	}
+	t.md[key] = value
+	return nil
+}
+
+func copyMetadata(md map[string]string) map[string]string {
+	if md == nil {
+		return nil
+	}
+	c := make(map[string]string, len(md))
+	for k, v := range md {
+		c[k] = v
+	}
+	return c
+}
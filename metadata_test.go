@@ -0,0 +1,42 @@
+package YARPC
+
+import (
+	"YARPC/codec"
+	"context"
+	"testing"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestMetadataAndTrailer(t *testing.T) {
+	forEachCodec(t, startServer(t, NewServer(), newEchoService()), func(t *testing.T, typ codec.Type, client *Client) {
+		ctx := WithMetadata(context.Background(), map[string]string{"tenant": "a", "token": "t1"})
+		ctx = WithMetadata(ctx, map[string]string{"tenant": "b"})
+		var reply wrapperspb.StringValue
+		call := <-client.GoContext(ctx, "Echo.Meta", wrapperspb.String("tenant"), &reply, nil).Done
+		if call.Error != nil {
+			t.Fatal(call.Error)
+		}
+		//后设置的键覆盖先设置的键
+		if reply.Value != "b" {
+			t.Fatalf("tenant = %q, want %q", reply.Value, "b")
+		}
+		if call.Trailer["key"] != "tenant" {
+			t.Fatalf("trailer = %v, want key=tenant", call.Trailer)
+		}
+		if err := client.CallContext(ctx, "Echo.Meta", wrapperspb.String("token"), &reply); err != nil || reply.Value != "t1" {
+			t.Fatalf("token = %q, %v; want %q, nil", reply.Value, err, "t1")
+		}
+		//没有元数据的调用，gob和json不会覆盖reply中已有的值，因此使用新的reply
+		var empty wrapperspb.StringValue
+		if err := client.Call("Echo.Meta", wrapperspb.String("tenant"), &empty); err != nil || empty.Value != "" {
+			t.Fatalf("tenant = %q, %v; want empty, nil", empty.Value, err)
+		}
+	})
+}
+
+func TestSetTrailerOutsideServer(t *testing.T) {
+	if err := SetTrailer(context.Background(), "k", "v"); err == nil {
+		t.Fatal("SetTrailer with a background context succeeded, want an error")
+	}
+}
@@ -0,0 +1,162 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+//YARegistry是一个简单的注册中心，提供以下功能：
+//	-添加一个服务端并接收它的心跳，使其保持存活
+//	-返回所有存活的服务端，同时删除超时的服务端
+//YARegistry实现了http.Handler，因此可以通过httptest在同一个进程中测试
+type YARegistry struct {
+	timeout time.Duration //服务端超过timeout没有发送心跳，即被认为已经失效，0表示永不过期
+	mu      sync.Mutex    //保护servers
+	servers map[string]*ServerItem
+}
+
+type ServerItem struct {
+	Addr  string
+	start time.Time //上一次收到心跳的时间
+}
+
+const (
+	defaultPath    = "/_yarpc_/registry"
+	defaultTimeout = time.Minute * 5
+	//GET请求的回复和POST请求都通过HTTP header传递服务端地址
+	serversHeader = "X-Yarpc-Servers"
+	serverHeader  = "X-Yarpc-Server"
+)
+
+//New创建一个注册中心实例，超过timeout没有收到心跳的服务端会被删除
+func New(timeout time.Duration) *YARegistry {
+	return &YARegistry{
+		servers: make(map[string]*ServerItem),
+		timeout: timeout,
+	}
+}
+
+var DefaultYARegistry = New(defaultTimeout)
+
+//putServer添加一个服务端，服务端已经存在时更新它的心跳时间
+func (r *YARegistry) putServer(addr string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.servers[addr]
+	if s == nil {
+		r.servers[addr] = &ServerItem{Addr: addr, start: time.Now()}
+	} else {
+		s.start = time.Now()
+	}
+}
+
+//aliveServers返回所有存活的服务端，并删除超时的服务端
+func (r *YARegistry) aliveServers() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var alive []string
+	for addr, s := range r.servers {
+		if r.timeout == 0 || s.start.Add(r.timeout).After(time.Now()) {
+			alive = append(alive, addr)
+		} else {
+			delete(r.servers, addr)
+		}
+	}
+	sort.Strings(alive)
+	return alive
+}
+
+//ServeHTTP：GET返回所有存活的服务端，POST添加服务端或更新其心跳
+func (r *YARegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case "GET":
+		//为了简单，服务端地址放在HTTP header中传递
+		w.Header().Set(serversHeader, strings.Join(r.aliveServers(), ","))
+	case "POST":
+		addr := req.Header.Get(serverHeader)
+		if addr == "" {
+			//缺少服务端地址是请求本身的问题，返回400而不是500
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		r.putServer(addr)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+//HandleHTTP在http.DefaultServeMux的registryPath上注册YARegistry
+func (r *YARegistry) HandleHTTP(registryPath string) {
+	http.Handle(registryPath, r)
+	log.Println("rpc registry path:", registryPath)
+}
+
+func HandleHTTP() {
+	DefaultYARegistry.HandleHTTP(defaultPath)
+}
+
+//Heartbeat每隔interval向注册中心发送一次心跳，第一次心跳在返回之前同步发送
+//服务端通常在调用Accept的同时运行它，interval应小于注册中心的timeout
+//某次心跳失败时只记录日志，下一次心跳会继续尝试，以免注册中心短暂不可用后服务端永久失联
+//返回的stop停止发送心跳(例如在Server.Shutdown之后)，会取消正在发送的心跳并等待后台协程退出，可以多次调用
+func Heartbeat(registry, addr string, interval time.Duration) (stop func()) {
+	if interval == 0 {
+		//确保在注册中心删除该服务端之前，有足够的时间发送心跳
+		interval = defaultTimeout - time.Duration(1)*time.Minute
+	}
+	//注册中心的地址不合法时重试也没有意义，只记录日志，不影响服务端的运行
+	if _, err := http.NewRequest("POST", registry, nil); err != nil {
+		log.Println("rpc server: heart beat to invalid registry:", err)
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	_ = sendHeartbeat(ctx, registry, addr)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				_ = sendHeartbeat(ctx, registry, addr)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func sendHeartbeat(ctx context.Context, registry, addr string) error {
+	httpClient := &http.Client{Timeout: time.Second * 10}
+	req, err := http.NewRequestWithContext(ctx, "POST", registry, nil)
+	if err != nil {
+		log.Println("rpc server: heart beat err:", err)
+		return err
+	}
+	req.Header.Set(serverHeader, addr)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Println("rpc server: heart beat err:", err)
+		}
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("rpc server: heart beat to %s: unexpected status %s", registry, resp.Status)
+		log.Println(err)
+		return err
+	}
+	return nil
+}
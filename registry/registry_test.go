@@ -0,0 +1,84 @@
+package registry
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+//startRegistry通过httptest在同一个进程中运行r，返回其地址
+func startRegistry(t *testing.T, r *YARegistry) string {
+	t.Helper()
+	ts := httptest.NewServer(r)
+	t.Cleanup(ts.Close)
+	return ts.URL
+}
+
+//alive通过GET请求返回注册中心中存活的服务端
+func alive(t *testing.T, registry string) string {
+	t.Helper()
+	resp, err := http.Get(registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	return resp.Header.Get(serversHeader)
+}
+
+func TestHeartbeatKeepsServerAlive(t *testing.T) {
+	registry := startRegistry(t, New(100*time.Millisecond))
+	stop := Heartbeat(registry, "tcp@a", 20*time.Millisecond)
+	//第一次心跳在Heartbeat返回之前发送
+	if got := alive(t, registry); got != "tcp@a" {
+		t.Fatalf("alive = %q after Heartbeat, want tcp@a", got)
+	}
+	time.Sleep(250 * time.Millisecond)
+	if got := alive(t, registry); got != "tcp@a" {
+		t.Fatalf("alive = %q while sending heartbeats, want tcp@a", got)
+	}
+	stop()
+	stop()
+	time.Sleep(150 * time.Millisecond)
+	if got := alive(t, registry); got != "" {
+		t.Fatalf("alive = %q after stop, want none", got)
+	}
+}
+
+func TestServerExpires(t *testing.T) {
+	registry := startRegistry(t, New(50*time.Millisecond))
+	for _, addr := range []string{"tcp@b", "tcp@a"} {
+		if err := sendHeartbeat(context.Background(), registry, addr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := alive(t, registry); got != "tcp@a,tcp@b" {
+		t.Fatalf("alive = %q, want tcp@a,tcp@b", got)
+	}
+	time.Sleep(80 * time.Millisecond)
+	if got := alive(t, registry); got != "" {
+		t.Fatalf("alive = %q after timeout, want none", got)
+	}
+}
+
+func TestPostWithoutServer(t *testing.T) {
+	resp, err := http.Post(startRegistry(t, New(0)), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+}
+
+func TestHeartbeatInvalidRegistry(t *testing.T) {
+	//不合法的地址只记录日志，不会panic
+	stop := Heartbeat("://bad", "tcp@a", time.Millisecond)
+	stop()
+	if err := sendHeartbeat(context.Background(), "://bad", "tcp@a"); err == nil || !strings.Contains(err.Error(), "missing protocol scheme") {
+		t.Fatalf("sendHeartbeat err = %v, want a URL error", err)
+	}
+}
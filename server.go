@@ -2,26 +2,81 @@ package YARPC
 
 import (
 	"YARPC/codec"
+	"bufio"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net"
+	"net/http"
 	"reflect"
+	"runtime/debug"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const MagicNumber = 0x3bef5c
 
+const (
+	//HTTP CONNECT成功后服务端返回的状态
+	connected = "200 Connected to YARPC"
+	//HandleHTTP注册RPC服务的固定路径
+	defaultRPCPath = "/_yarpc_"
+	//HandleHTTP注册调试页面的路径
+	defaultDebugPath = "/debug/yarpc"
+)
+
 type Option struct {
 	//MagicNumber表示这是一个YA-RPC请求
 	MagicNumber int
 	//客户端可以选择不同的codec进行解码
 	CodecType codec.Type
+	//建立连接的超时时间，包括TCP连接和发送Option两个阶段，0表示不设限
+	ConnectTimeout time.Duration
+	//每次Call的超时时间，0表示不设限
+	CallTimeout time.Duration
+	//服务端处理一次请求的超时时间，0表示不设限
+	HandleTimeout time.Duration
 }
 type Server struct {
-	serviceMap sync.Map
+	serviceMap sync.Map   //读取不需要加锁，写入需要持有mu，使RegisterFunc的读取-复制-写入成为原子操作
+	mu         sync.Mutex //保护以下字段
+	inShutdown bool       //Shutdown被调用后置为true，此后不再接受新的连接
+	listeners  map[net.Listener]struct{}
+	conns      map[*serverConn]struct{}
+	connWg     sync.WaitGroup //等待所有连接处理完毕
+	//通过Use添加的拦截器
+	interceptors []ServerInterceptor
+	//记录方法panic时的调用栈，为nil时使用标准库的log
+	logger Logger
+	//为true时，Register遇到不符合条件的exported方法会返回错误
+	strictRegister bool
+}
+
+//Logger用于输出服务端的诊断信息，*log.Logger满足该接口
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+//SetLogger设置记录panic调用栈的Logger
+func (server *Server) SetLogger(logger Logger) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	server.logger = logger
+}
+
+func (server *Server) getLogger() Logger {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.logger == nil {
+		return log.Default()
+	}
+	return server.logger
 }
 
 //request存储了来自一次call的所有信息
@@ -30,11 +85,15 @@ type request struct {
 	argv, replyv reflect.Value //一个请求的argv和replyv部分
 	mtype        *methodType
 	svc          *service
+	ctx          context.Context //携带客户端传来的截止时间和元数据，方法调用结束后通过cancel释放
+	cancel       context.CancelFunc
+	trailer      *trailer //方法通过SetTrailer设置的回复元数据
 }
 
 var DefaultOption = &Option{
-	MagicNumber: MagicNumber,
-	CodecType:   codec.GobType,
+	MagicNumber:    MagicNumber,
+	CodecType:      codec.GobType,
+	ConnectTimeout: time.Second * 10,
 }
 
 /*
@@ -51,11 +110,19 @@ func NewServer() *Server {
 
 var DefaultServer = NewServer()
 
+//Accept在lis上接受连接，直到lis出错或者Shutdown被调用
 func (server *Server) Accept(lis net.Listener) {
+	if !server.trackListener(lis, true) {
+		_ = lis.Close()
+		return
+	}
+	defer server.trackListener(lis, false)
 	for {
 		conn, err := lis.Accept()
 		if err != nil {
-			log.Println("rpc server:accept error:", err)
+			if !server.shuttingDown() {
+				log.Println("rpc server:accept error:", err)
+			}
 			return
 		}
 		go server.ServeConn(conn)
@@ -68,9 +135,18 @@ func Accept(lis net.Listener) { DefaultServer.Accept(lis) }
 func (server *Server) ServeConn(conn io.ReadWriteCloser) {
 	//"_"的作用是占位符，比如当需要获取某个函数的返回值时，如果该函数有多个返回值，而现在只需要其中的一部分，就可以将不使用的返回值用"_"表示，因为如果使用变量表示，而后续的代码中又没有使用，编译器会报错
 	defer func() { _ = conn.Close() }()
+	//记录这个连接，以便Shutdown时停止读取新的请求，或在超时后强制关闭
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sc := &serverConn{rwc: conn, cancel: cancel}
+	if !server.trackConn(sc, true) {
+		return
+	}
+	defer server.trackConn(sc, false)
 	var opt Option
 	//解析报文中为json格式的option部分
-	if err := json.NewDecoder(conn).Decode(&opt); err != nil {
+	dec := json.NewDecoder(conn)
+	if err := dec.Decode(&opt); err != nil {
 		log.Println("rpc server: options error: ", err)
 		return
 	}
@@ -78,13 +154,30 @@ func (server *Server) ServeConn(conn io.ReadWriteCloser) {
 		log.Printf("rpc server: invalid magic number %x", opt.MagicNumber)
 		return
 	}
-	f := codec.NewCodecFuncMap[opt.CodecType]
-	if f == nil {
+	f, ok := codec.Lookup(opt.CodecType)
+	if !ok {
 		log.Printf("rpc server: invalid codec type %s", opt.CodecType)
 		return
 	}
+	//json.Decoder可能会多读入option之后的内容，这些内容需要交还给codec
+	//json.Encoder会在option之后写入一个换行符，这里将其跳过
+	r := bufio.NewReader(io.MultiReader(dec.Buffered(), conn))
+	if b, err := r.Peek(1); err == nil && b[0] == '\n' {
+		_, _ = r.Discard(1)
+	}
+	conn = &handshakeConn{r: r, ReadWriteCloser: conn}
 	//serveCodec用来进一步解析报文中的其他部分
-	server.serveCodec(f(conn))
+	server.serveCodec(ctx, f(conn), &opt)
+}
+
+//handshakeConn在读取时先返回解析option时被json.Decoder缓冲的数据，再继续从连接中读取
+type handshakeConn struct {
+	r io.Reader
+	io.ReadWriteCloser
+}
+
+func (c *handshakeConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
 }
 
 //当发生错误时，无效的请求应该设置成一个占位符，以方便响应结果的返回，这里使用空struct作为占位符
@@ -96,20 +189,23 @@ serveCodec的过程为：
 2.处理请求 handleRequest
 3.回复请求 sendResponse
 */
-func (server *Server) serveCodec(cc codec.Codec) {
+func (server *Server) serveCodec(ctx context.Context, cc codec.Codec, opt *Option) {
 	//处理请求可以是并发的，但对请求的回复必须是逐个发送的，如果并发会导致多个回复报文交织在一起导致客户端无法解析，这里使用锁来解决这个问题
 	sending := new(sync.Mutex)
 	//等待，直到所有的请求处理完成
 	wg := new(sync.WaitGroup)
+	//连接断开(读取请求失败)时取消ctx，通知所有仍在执行的方法
+	ctx, cancel := context.WithCancel(ctx)
 
 	//在一次连接中可能会收到多个请求，因此使用for循环无限制地等待请求的到来，直到发生错误(如连接被关闭，或接收到了错误报文)
 	for {
-		req, err := server.readRequest(cc)
+		req, err := server.readRequest(ctx, cc)
 		if err != nil {
 			if req == nil {
 				break
 			}
-			req.h.Error = err.Error()
+			setHeaderError(req.h, err)
+			req.h.Metadata = nil
 			server.sendResponse(cc, req.h, invalidRequest, sending)
 			continue
 		}
@@ -117,10 +213,15 @@ func (server *Server) serveCodec(cc codec.Codec) {
 		wg.Add(1)
 		//使用协程并发地执行请求
 		//go关键字放在方法调用前新建一个goroutine并让它执行方法体
-		go server.handleRequest(cc, req, sending, wg)
+		go server.handleRequest(cc, req, sending, wg, opt.HandleTimeout)
+	}
+	//Shutdown导致的停止读取并不是连接断开，已经在处理的请求应该正常完成
+	if !server.shuttingDown() {
+		cancel()
 	}
 	//sync.WaitGroup.Wait会在计数器大于0并且不存在等待的Goroutine时，将该进程置为睡眠
 	wg.Wait()
+	cancel()
 	_ = cc.Close()
 }
 
@@ -141,7 +242,7 @@ func (server *Server) serveCodec(cc codec.Codec) {
 
 //readRequest()中最重要的部分，是通过newArgv()和newReplyv()两个方法创建出两个入参实例，
 //然后通过cc.ReadBody()将请求报文反序列化为第一个入参argv,这里需要注意argv可能是值类型，也可能是指针类型，处理方式有些差异
-func (server *Server) readRequest(cc codec.Codec) (*request, error) {
+func (server *Server) readRequest(ctx context.Context, cc codec.Codec) (*request, error) {
 	h, err := server.readRequestHeader(cc)
 	if err != nil {
 		return nil, err
@@ -149,6 +250,8 @@ func (server *Server) readRequest(cc codec.Codec) (*request, error) {
 	req := &request{h: h}
 	req.svc, req.mtype, err = server.findService(h.ServiceMethod)
 	if err != nil {
+		//丢弃这个请求的Body，否则它会被当作下一个请求的Header读取
+		_ = cc.ReadBody(nil)
 		return req, err
 	}
 	req.argv = req.mtype.newArgv()
@@ -161,32 +264,96 @@ func (server *Server) readRequest(cc codec.Codec) (*request, error) {
 	}
 	if err = cc.ReadBody(argvi); err != nil {
 		log.Println("rpc server: read body err:", err)
-		return req, err
+		return req, NewStatus(InvalidArgument, "rpc server: read body err: "+err.Error())
+	}
+	//codec可以实现codec.BodyChecker，在调用方法之前拒绝它无法编码的reply类型，否则方法执行完毕后回复无法发送
+	if bc, ok := cc.(codec.BodyChecker); ok {
+		if err := bc.CheckBody(req.mtype.ReplyType); err != nil {
+			return req, Errorf(InvalidArgument, "rpc server: %s: %v", h.ServiceMethod, err)
+		}
+	}
+	//将header中的剩余时间转换为ctx，客户端放弃等待后服务端也可以随之放弃
+	if h.Timeout > 0 {
+		req.ctx, req.cancel = context.WithTimeout(ctx, h.Timeout)
+	} else {
+		req.ctx, req.cancel = context.WithCancel(ctx)
 	}
+	if h.Metadata != nil {
+		req.ctx = context.WithValue(req.ctx, incomingMetadataKey{}, h.Metadata)
+	}
+	req.trailer = new(trailer)
+	req.ctx = context.WithValue(req.ctx, trailerKey{}, req.trailer)
 	return req, nil
 }
 func (server *Server) readRequestHeader(cc codec.Codec) (*codec.Header, error) {
 	var h codec.Header
 	if err := cc.ReadHeader(&h); err != nil {
-		//如果错误信息不是关于EOF的，通过log打印出错误信息
-		if err != io.EOF && err != io.ErrUnexpectedEOF {
+		//如果错误信息不是关于EOF的，通过log打印出错误信息，Shutdown导致的读取超时也不打印
+		if err != io.EOF && err != io.ErrUnexpectedEOF && !server.shuttingDown() {
 			log.Println("rpc server: read header error: ", err)
 		}
 		return nil, err
 	}
 	return &h, nil
 }
-func (server *Server) handleRequest(cc codec.Codec, req *request, sending *sync.Mutex, wg *sync.WaitGroup) {
+
+//handleRequest在timeout内没有完成方法调用时，立即回复一个超时错误
+//超时后方法调用仍在子协程中继续执行，但其结果会被丢弃，不会对同一个seq发送第二次回复
+func (server *Server) handleRequest(cc codec.Codec, req *request, sending *sync.Mutex, wg *sync.WaitGroup, timeout time.Duration) {
 	defer wg.Done()
-	//通过req.svc.call完成方法调用
-	err := req.svc.call(req.mtype, req.argv, req.replyv)
-	if err != nil {
-		req.h.Error = err.Error()
-		server.sendResponse(cc, req.h, invalidRequest, sending)
-		return
+	defer req.cancel()
+	//called带有缓冲，保证超时后子协程仍然可以写入并退出
+	called := make(chan error, 1)
+	go func() {
+		//经过拦截器后，通过req.svc.call完成方法调用
+		err := server.safeInvoke(req)
+		if err != nil {
+			atomic.AddUint64(&req.mtype.numErrors, 1)
+		}
+		called <- err
+	}()
+	var timer <-chan time.Time
+	if timeout > 0 {
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+		timer = t.C
+	}
+	//超时后拦截器可能仍在读取req.h，因此回复使用一个新的Header，其中携带trailer而不是请求的元数据
+	h := &codec.Header{ServiceMethod: req.h.ServiceMethod, Seq: req.h.Seq}
+	select {
+	case <-timer:
+		h.Metadata = req.trailer.get()
+		setHeaderError(h, Errorf(DeadlineExceeded, "rpc server: request handle timeout: expect within %s", timeout))
+		server.sendResponse(cc, h, invalidRequest, sending)
+	case <-req.ctx.Done():
+		//客户端已经放弃等待，或者连接已经断开(包括Shutdown强制关闭)，回复的错误会被客户端丢弃，这里只是为了尽早释放wg
+		//ctx因超时结束时对应DeadlineExceeded，被取消时对应Canceled
+		h.Metadata = req.trailer.get()
+		st := StatusFromError(req.ctx.Err())
+		setHeaderError(h, Errorf(st.Code, "rpc server: request %s", st.Message))
+		server.sendResponse(cc, h, invalidRequest, sending)
+	case err := <-called:
+		h.Metadata = req.trailer.get()
+		if err != nil {
+			setHeaderError(h, err)
+			server.sendResponse(cc, h, invalidRequest, sending)
+			return
+		}
+		//将replyv传递给sendResponse完成序列化
+		server.sendResponse(cc, h, req.replyv.Interface(), sending)
 	}
-	//将replyv传递给sendResponse完成序列化
-	server.sendResponse(cc, req.h, req.replyv.Interface(), sending)
+}
+
+//safeInvoke恢复方法或拦截器中发生的panic，避免其导致整个服务端进程退出
+//panic被转换为一个Internal错误，调用栈通过server的Logger输出
+func (server *Server) safeInvoke(req *request) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			server.getLogger().Printf("rpc server: panic in %s: %v\n%s", req.h.ServiceMethod, r, debug.Stack())
+			err = Errorf(Internal, "rpc server: panic in %s: %v", req.h.ServiceMethod, r)
+		}
+	}()
+	return server.invoke(req)
 }
 
 // 未更新service.go前的handleRequest()
@@ -206,6 +373,15 @@ func (server *Server) sendResponse(cc codec.Codec, h *codec.Header, body interfa
 	defer sending.Unlock()
 	if err := cc.Write(h, body); err != nil {
 		log.Println("rpc server: write response error:", err)
+		//Body无法编码时改为回复一个Internal错误，否则客户端永远收不到这个seq的回复
+		//如果codec在出错时已经关闭了连接，这次写入也会失败，客户端会因连接断开而结束调用
+		if h.Error == "" {
+			eh := &codec.Header{ServiceMethod: h.ServiceMethod, Seq: h.Seq, Metadata: h.Metadata}
+			setHeaderError(eh, Errorf(Internal, "rpc server: write response error: %v", err))
+			if err := cc.Write(eh, invalidRequest); err != nil {
+				log.Println("rpc server: write response error:", err)
+			}
+		}
 	}
 }
 
@@ -213,39 +389,241 @@ func (server *Server) sendResponse(cc codec.Codec, h *codec.Header, body interfa
 //	-类型为exported
 //	-两个参数，均为exported
 //	-第二个参数是一个指针
+//	-可以在两个参数之前额外接收一个context.Context
 //	-只有一个类型为error的返回值
+//类型名不合法、没有任何符合条件的方法时返回错误；其他不符合条件的exported方法会被跳过并记录原因，
+//在SetStrictRegister(true)之后则返回错误
 func (server *Server) Register(rcvr interface{}) error {
-	s := newService(rcvr)
-	if _, dup := server.serviceMap.LoadOrStore(s.name, s); dup {
+	return server.register(rcvr, "", false)
+}
+
+//RegisterName与Register类似，但使用name而不是rcvr的类型名作为服务名，
+//因此同一个类型的多个实例可以分别注册，例如Shard0和Shard1；name可以包含"."，例如billing.v2.Invoice
+func (server *Server) RegisterName(name string, rcvr interface{}) error {
+	return server.register(rcvr, name, true)
+}
+
+func (server *Server) register(rcvr interface{}, name string, useName bool) error {
+	s, err := newService(rcvr, name, useName)
+	if err != nil {
+		return err
+	}
+	if err := server.registerMethods(s); err != nil {
+		return err
+	}
+	server.mu.Lock()
+	_, dup := server.serviceMap.LoadOrStore(s.name, s)
+	server.mu.Unlock()
+	if dup {
 		return errors.New("rpc: service already defined: " + s.name)
 	}
+	logRegistered(s)
 	return nil
 }
 
+//Unregister从server中移除名为name的服务，之后的请求会得到NotFound错误，
+//已经找到该服务的请求持有*service的引用，不受影响，会正常执行完毕
+func (server *Server) Unregister(name string) error {
+	server.mu.Lock()
+	_, ok := server.serviceMap.LoadAndDelete(name)
+	server.mu.Unlock()
+	if !ok {
+		return errors.New("rpc server: service not registered: " + name)
+	}
+	log.Printf("rpc server: unregister %s\n", name)
+	return nil
+}
+
+//Replace使用rcvr替换名为name的服务，name尚未注册时等同于RegisterName
+//替换通过一次serviceMap.Store完成，正在执行的请求仍然使用旧的rcvr，之后的请求使用新的rcvr
+func (server *Server) Replace(name string, rcvr interface{}) error {
+	s, err := newService(rcvr, name, true)
+	if err != nil {
+		return err
+	}
+	if err := server.registerMethods(s); err != nil {
+		return err
+	}
+	server.mu.Lock()
+	server.serviceMap.Store(s.name, s)
+	server.mu.Unlock()
+	logRegistered(s)
+	return nil
+}
+
+//RegisterFunc将函数fn注册为serviceMethod("Service.Method")，fn不需要接收者，
+//需要满足和方法相同的条件：接收可选的context.Context、args和*reply，并且只返回一个error
+//同一个Service可以通过多次RegisterFunc注册多个函数，但不能和Register注册的service重名
+func (server *Server) RegisterFunc(serviceMethod string, fn interface{}) error {
+	dot := strings.LastIndex(serviceMethod, ".")
+	if dot < 0 {
+		return errors.New("rpc server: func name must be of the form Service.Method: " + serviceMethod)
+	}
+	if err := checkServiceName(serviceMethod); err != nil {
+		return err
+	}
+	serviceName, methodName := serviceMethod[:dot], serviceMethod[dot+1:]
+	f := reflect.ValueOf(fn)
+	if f.Kind() != reflect.Func {
+		return fmt.Errorf("rpc server: func %s is %T, not a function", serviceMethod, fn)
+	}
+	if f.IsNil() {
+		return errors.New("rpc server: register nil func " + serviceMethod)
+	}
+	hasCtx, argType, replyType, err := checkMethodType(f.Type(), 0)
+	if err != nil {
+		return fmt.Errorf("rpc server: func %s %v", serviceMethod, err)
+	}
+	mtype := &methodType{
+		method:    reflect.Method{Name: methodName, Type: f.Type(), Func: f},
+		ArgType:   argType,
+		ReplyType: replyType,
+		hasCtx:    hasCtx,
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	s := &service{name: serviceName, method: map[string]*methodType{methodName: mtype}}
+	if svci, ok := server.serviceMap.Load(serviceName); ok {
+		old := svci.(*service)
+		if old.rcvr.IsValid() {
+			return errors.New("rpc: service already defined: " + serviceName)
+		}
+		if _, dup := old.method[methodName]; dup {
+			return errors.New("rpc: method already defined: " + serviceMethod)
+		}
+		//不修改old.method，而是复制一份后替换整个service，避免和正在读取old.method的请求产生数据竞争
+		for name, m := range old.method {
+			s.method[name] = m
+		}
+		server.serviceMap.Store(serviceName, s)
+	} else if _, dup := server.serviceMap.LoadOrStore(serviceName, s); dup {
+		return errors.New("rpc: service already defined: " + serviceName)
+	}
+	log.Printf("rpc server: register %s\n", serviceMethod)
+	return nil
+}
+
+//registerMethods注册s的方法，并根据是否为严格模式处理被跳过的方法
+func (server *Server) registerMethods(s *service) error {
+	server.mu.Lock()
+	strict := server.strictRegister
+	server.mu.Unlock()
+	skipped := s.registerMethods()
+	if len(s.method) == 0 {
+		msg := "rpc server: type " + s.typ.String() + " has no exported methods of suitable type"
+		//方法的接收者是指针时，只有注册指针才能找到这些方法
+		if s.typ.Kind() != reflect.Ptr && reflect.PtrTo(s.typ).NumMethod() > 0 {
+			msg += " (hint: pass a pointer to value of that type)"
+		}
+		if len(skipped) > 0 {
+			msg += ": " + strings.Join(skipped, "; ")
+		}
+		return errors.New(msg)
+	}
+	if len(skipped) > 0 && strict {
+		return errors.New("rpc server: " + strings.Join(skipped, "; "))
+	}
+	for _, reason := range skipped {
+		log.Printf("rpc server: skip %s\n", reason)
+	}
+	return nil
+}
+
+//logRegistered按照方法名的顺序记录s中注册的方法
+func logRegistered(s *service) {
+	names := make([]string, 0, len(s.method))
+	for name := range s.method {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		log.Printf("rpc server: register %s.%s\n", s.name, name)
+	}
+}
+
+//SetStrictRegister设置严格模式，严格模式下Register遇到任何不符合条件的exported方法都会返回错误
+func (server *Server) SetStrictRegister(strict bool) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	server.strictRegister = strict
+}
+
 //DefaultServer的Register
 func Register(rcvr interface{}) error {
 	return DefaultServer.Register(rcvr)
 }
 
+//DefaultServer的RegisterName
+func RegisterName(name string, rcvr interface{}) error {
+	return DefaultServer.RegisterName(name, rcvr)
+}
+
+//DefaultServer的RegisterFunc
+func RegisterFunc(serviceMethod string, fn interface{}) error {
+	return DefaultServer.RegisterFunc(serviceMethod, fn)
+}
+
+//DefaultServer的Unregister
+func Unregister(name string) error {
+	return DefaultServer.Unregister(name)
+}
+
+//DefaultServer的Replace
+func Replace(name string, rcvr interface{}) error {
+	return DefaultServer.Replace(name, rcvr)
+}
+
 //findService()的逻辑：
 //	1.ServiceMethod的构成是"Service.Method"，因此先将其分割成2部分，第一部分是Service的名称，第二部分即方法名
 //	2.先在serviceMap中找到对应的service实例，再从service实例的method中，找到对应的methodType
 func (server *Server) findService(serviceMethod string) (svc *service, mtype *methodType, err error) {
 	dot := strings.LastIndex(serviceMethod, ".")
 	if dot < 0 {
-		err = errors.New("rpc server: service/method request ill-formed: " + serviceMethod)
+		err = NewStatus(InvalidArgument, "rpc server: service/method request ill-formed: "+serviceMethod)
 		return
 	}
 	serviceName, methodName := serviceMethod[:dot], serviceMethod[dot+1:]
 	svci, ok := server.serviceMap.Load(serviceName)
 	if !ok {
-		err = errors.New("rpc server:can't find service " + serviceName)
+		err = NewStatus(NotFound, "rpc server:can't find service "+serviceName)
 		return
 	}
 	svc = svci.(*service)
 	mtype = svc.method[methodName]
 	if mtype == nil {
-		err = errors.New("rpc server: can't find method " + methodName)
+		err = NewStatus(NotFound, "rpc server: can't find method "+methodName)
 	}
 	return
 }
+
+//ServeHTTP使Server实现http.Handler，客户端通过CONNECT请求建立连接后，该连接被劫持(hijack)并交给ServeConn处理
+//这样RPC和普通的HTTP服务可以共用一个端口
+func (server *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "CONNECT" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		_, _ = io.WriteString(w, "405 must CONNECT\n")
+		return
+	}
+	conn, _, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		log.Print("rpc hijacking ", req.RemoteAddr, ": ", err.Error())
+		return
+	}
+	_, _ = io.WriteString(conn, "HTTP/1.0 "+connected+"\n\n")
+	server.ServeConn(conn)
+}
+
+//HandleHTTP在http.DefaultServeMux的defaultRPCPath上注册server，在defaultDebugPath上注册调试页面
+//之后仍需调用http.Serve
+func (server *Server) HandleHTTP() {
+	http.Handle(defaultRPCPath, server)
+	http.Handle(defaultDebugPath, server.DebugHandler())
+	log.Println("rpc server debug path:", defaultDebugPath)
+}
+
+//为DefaultServer设置的HandleHTTP方法
+func HandleHTTP() {
+	DefaultServer.HandleHTTP()
+}
@@ -0,0 +1,250 @@
+package YARPC
+
+import (
+	"YARPC/codec"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+//echoService是测试使用的服务，类型本身不是exported的，因此通过RegisterName注册为Echo
+type echoService struct {
+	started chan struct{} //Block开始执行时写入
+	release chan struct{} //关闭后Block返回
+}
+
+func newEchoService() *echoService {
+	return &echoService{
+		started: make(chan struct{}, 10),
+		release: make(chan struct{}),
+	}
+}
+
+//Double返回args的两倍
+func (s *echoService) Double(args int, reply *int) error {
+	*reply = args * 2
+	return nil
+}
+
+//Upper返回大写的args，参数使用wrapperspb以便所有的codec(包括ProtoCodec)都可以编码
+func (s *echoService) Upper(args *wrapperspb.StringValue, reply *wrapperspb.StringValue) error {
+	reply.Value = strings.ToUpper(args.Value)
+	return nil
+}
+
+//Fail总是返回一个带有Details的*Status
+func (s *echoService) Fail(args *wrapperspb.StringValue, reply *wrapperspb.StringValue) error {
+	return NewStatus(NotFound, "echo: not found", "detail")
+}
+
+//Len的reply不是proto.Message，不能通过ProtoCodec回复
+func (s *echoService) Len(args *wrapperspb.StringValue, reply *int) error {
+	*reply = len(args.Value)
+	return nil
+}
+
+//InvalidUTF8返回一个ProtoCodec无法编码的reply
+func (s *echoService) InvalidUTF8(args *wrapperspb.StringValue, reply *wrapperspb.StringValue) error {
+	reply.Value = "\xff"
+	return nil
+}
+
+//Panic总是panic，用于测试服务端的恢复
+func (s *echoService) Panic(args int, reply *int) error {
+	panic("echo: boom")
+}
+
+//Meta返回请求元数据中键为args的值，并通过trailer将args回传给客户端
+func (s *echoService) Meta(ctx context.Context, args *wrapperspb.StringValue, reply *wrapperspb.StringValue) error {
+	md, _ := MetadataFromContext(ctx)
+	reply.Value = md[args.Value]
+	return SetTrailer(ctx, "key", args.Value)
+}
+
+//Block在release被关闭或ctx结束之前一直阻塞，用于构造正在处理中的请求
+func (s *echoService) Block(ctx context.Context, args int, reply *int) error {
+	s.started <- struct{}{}
+	select {
+	case <-s.release:
+		*reply = args
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+//startServer在一个随机端口上运行注册了Echo服务的server，返回其地址
+func startServer(t *testing.T, server *Server, svc *echoService) string {
+	t.Helper()
+	if err := server.RegisterName("Echo", svc); err != nil {
+		t.Fatal(err)
+	}
+	return serve(t, server)
+}
+
+//serve在一个随机端口上运行server，返回其地址
+func serve(t *testing.T, server *Server) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept(l)
+	t.Cleanup(func() { _ = l.Close() })
+	return l.Addr().String()
+}
+
+func TestHandleTimeoutSendsOneResponse(t *testing.T) {
+	svc := newEchoService()
+	addr := startServer(t, NewServer(), svc)
+	//直接使用codec收发报文，以便检查连接上的每一个回复
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	opt := &Option{MagicNumber: MagicNumber, CodecType: codec.GobType, HandleTimeout: 50 * time.Millisecond}
+	if err := json.NewEncoder(conn).Encode(opt); err != nil {
+		t.Fatal(err)
+	}
+	cc := codec.NewGobCodec(conn)
+	if err := cc.Write(&codec.Header{ServiceMethod: "Echo.Block", Seq: 1}, 7); err != nil {
+		t.Fatal(err)
+	}
+
+	var h codec.Header
+	if err := cc.ReadHeader(&h); err != nil {
+		t.Fatal(err)
+	}
+	if h.Seq != 1 || Code(h.ErrorCode) != DeadlineExceeded || !strings.Contains(h.Error, "handle timeout") {
+		t.Fatalf("header = %+v, want a handle timeout error for seq 1", h)
+	}
+	_ = cc.ReadBody(nil)
+
+	//方法在超时之后才结束，此时不应该再有第二个回复
+	close(svc.release)
+	_ = conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	var ne net.Error
+	if err := cc.ReadHeader(&h); !errors.As(err, &ne) || !ne.Timeout() {
+		t.Fatalf("second read = %+v, %v; want read timeout", h, err)
+	}
+}
+
+//logRecorder是记录所有输出的Logger
+type logRecorder struct {
+	mu   sync.Mutex
+	logs []string
+}
+
+func (l *logRecorder) Printf(format string, v ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.logs = append(l.logs, fmt.Sprintf(format, v...))
+}
+
+func (l *logRecorder) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return strings.Join(l.logs, "\n")
+}
+
+func TestPanicRecovered(t *testing.T) {
+	server := NewServer()
+	var logger logRecorder
+	server.SetLogger(&logger)
+	client := dialEcho(t, startServer(t, server, newEchoService()))
+	var reply int
+	err := client.Call("Echo.Panic", 1, &reply)
+	if StatusCode(err) != Internal || !strings.Contains(err.Error(), "Echo.Panic") || !strings.Contains(err.Error(), "echo: boom") {
+		t.Fatalf("err = %v, want an Internal error naming Echo.Panic", err)
+	}
+	if out := logger.String(); !strings.Contains(out, "echo: boom") || !strings.Contains(out, "goroutine") {
+		t.Fatalf("log = %q, want the panic value and a stack trace", out)
+	}
+	_, mtype, _ := server.findService("Echo.Panic")
+	if n := mtype.NumErrors(); n != 1 {
+		t.Fatalf("NumErrors = %d, want 1", n)
+	}
+	//panic之后连接仍然可用
+	if err := client.Call("Echo.Double", 21, &reply); err != nil || reply != 42 {
+		t.Fatalf("Call = %d, %v; want 42, nil", reply, err)
+	}
+}
+
+func TestInterceptorPanicRecovered(t *testing.T) {
+	server := NewServer()
+	server.SetLogger(&logRecorder{})
+	server.Use(func(ctx context.Context, info *ServerInfo, argv, replyv interface{}, next ServerHandler) error {
+		panic("interceptor: boom")
+	})
+	client := dialEcho(t, startServer(t, server, newEchoService()))
+	var reply int
+	if err := client.Call("Echo.Double", 21, &reply); StatusCode(err) != Internal || !strings.Contains(err.Error(), "Echo.Double") {
+		t.Fatalf("err = %v, want an Internal error naming Echo.Double", err)
+	}
+}
+
+func TestReplaceAndUnregister(t *testing.T) {
+	server := NewServer()
+	old := newEchoService()
+	client := dialEcho(t, startServer(t, server, old))
+
+	//旧的rcvr上正在执行的请求
+	var oldReply int
+	oldCall := client.Go("Echo.Block", 1, &oldReply, nil)
+	<-old.started
+
+	svc := newEchoService()
+	close(svc.release)
+	if err := server.Replace("Echo", svc); err != nil {
+		t.Fatal(err)
+	}
+	//之后的请求使用新的rcvr
+	var reply int
+	if err := client.Call("Echo.Block", 2, &reply); err != nil || reply != 2 {
+		t.Fatalf("Call = %d, %v; want 2, nil", reply, err)
+	}
+	select {
+	case <-svc.started:
+	default:
+		t.Fatal("new call did not reach the new receiver")
+	}
+	select {
+	case <-oldCall.Done:
+		t.Fatal("in-flight call finished before the old receiver released it")
+	default:
+	}
+	close(old.release)
+	<-oldCall.Done
+	if oldCall.Error != nil || oldReply != 1 {
+		t.Fatalf("in-flight call = %d, %v; want 1, nil", oldReply, oldCall.Error)
+	}
+
+	if err := server.Replace("Echo", (*echoService)(nil)); err == nil || !strings.Contains(err.Error(), "nil pointer receiver") {
+		t.Fatalf("Replace err = %v, want a nil pointer receiver error", err)
+	}
+	if err := server.Unregister("Echo"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Call("Echo.Double", 1, &reply); StatusCode(err) != NotFound {
+		t.Fatalf("Call after Unregister err = %v, want NotFound", err)
+	}
+	if err := server.Unregister("Echo"); err == nil || !strings.Contains(err.Error(), "not registered") {
+		t.Fatalf("second Unregister err = %v, want a not registered error", err)
+	}
+	//name尚未注册时Replace等同于RegisterName
+	if err := server.Replace("Echo", svc); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Call("Echo.Double", 21, &reply); err != nil || reply != 42 {
+		t.Fatalf("Call = %d, %v; want 42, nil", reply, err)
+	}
+}
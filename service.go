@@ -1,21 +1,44 @@
 package YARPC
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"go/ast"
-	"log"
 	"reflect"
+	"strings"
+	"sync/atomic"
+	"unicode"
 )
 
 type methodType struct {
-	method    reflect.Method //方法本身
+	method    reflect.Method //方法本身，通过RegisterFunc注册时Func为函数本身
 	ArgType   reflect.Type   //第一个参数的类型
 	ReplyType reflect.Type   //第二个参数的类型
+	hasCtx    bool           //方法的第一个参数是否为context.Context
+	numCalls  uint64         //方法被调用的次数，需要通过atomic读写
+	numErrors uint64         //方法返回错误(包括panic)的次数，需要通过atomic读写
 }
 
+//NumCalls返回方法被调用的次数，供调试页面展示
+func (m *methodType) NumCalls() uint64 {
+	return atomic.LoadUint64(&m.numCalls)
+}
+
+//NumErrors返回方法返回错误(包括panic)的次数，供调试页面展示
+func (m *methodType) NumErrors() uint64 {
+	return atomic.LoadUint64(&m.numErrors)
+}
+
+var (
+	typeOfContext = reflect.TypeOf((*context.Context)(nil)).Elem()
+	typeOfError   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
 type service struct {
 	name   string                 //映射的结构体的名称
 	typ    reflect.Type           //结构体的类型
-	rcvr   reflect.Value          //结构体的实例本身，保留rcvr是因为在调用时需要rcvr作为第0个参数
+	rcvr   reflect.Value          //结构体的实例本身，保留rcvr是因为在调用时需要rcvr作为第0个参数；通过RegisterFunc注册的service没有rcvr
 	method map[string]*methodType //method是map类型，用来存储映射的结构体的所有符合条件的方法
 }
 
@@ -23,13 +46,9 @@ func (m *methodType) newArgv() reflect.Value {
 	var argv reflect.Value
 	//argv可能是一个指针类型，或者值类型
 	if m.ArgType.Kind() == reflect.Ptr {
-		//这里的Elem方法返回该指针或者接口具体指向的类型
-		//假设ArgType是string*,则最终返回的是一个代表string*的reflect.value
-		//注：这里不能写成reflect.New(m.ArgType).Elem()
-		//因为虽然这个式子返回的仍然是一个代表string*的reflect.value
-		//但由于reflect.New()方法创建的是一个指向对应类型零值的指针，string类型的零值是"",
-		//可以创建一个指向它的指针，这个指针的值是一个地址，而string*类型的零值则是nil,指向它的指针的值是一个指向nil的string**,再取Elem()，得到的是一个值为nil的string*
-		argv = reflect.New(m.ArgType).Elem()
+		//ArgType是指针时，创建一个指向零值的指针，例如ArgType为*Args时返回一个代表&Args{}的reflect.Value
+		//注：这里不能写成reflect.New(m.ArgType).Elem()，那样得到的是一个值为nil的*Args，无法作为ReadBody的参数
+		argv = reflect.New(m.ArgType.Elem())
 	} else {
 		//reflect.Value.Elem()用于获取一个指针对象的真正的值
 		//New returns a Value representing a pointer to a new zero value for the specified type.
@@ -55,55 +74,138 @@ func (m *methodType) newReplyv() reflect.Value {
 	return replyv
 }
 
-func newService(rcvr interface{}) *service {
+//newService检查服务名，返回的service还需要调用registerMethods注册方法
+//useName为false时服务名取rcvr的类型名，此时类型必须是exported的；为true时使用name
+func newService(rcvr interface{}, name string, useName bool) (*service, error) {
+	if rcvr == nil {
+		return nil, errors.New("rpc server: register nil receiver")
+	}
 	s := new(service)
 	s.rcvr = reflect.ValueOf(rcvr)
+	s.typ = reflect.TypeOf(rcvr)
+	//nil指针没有可以取得类型名的值，也不能作为方法的接收者，无论是否指定name都不能注册
+	if s.typ.Kind() == reflect.Ptr && s.rcvr.IsNil() {
+		return nil, fmt.Errorf("rpc server: register nil pointer receiver of type %s", s.typ)
+	}
+	if useName {
+		if err := checkServiceName(name); err != nil {
+			return nil, err
+		}
+		s.name = name
+		return s, nil
+	}
 	//reflect.Indirect(v reflect.Value)用于获取v指向的值
 	s.name = reflect.Indirect(s.rcvr).Type().Name()
-	s.typ = reflect.TypeOf(rcvr)
 	//ast.IsExported()判断该类型是不是exported的
 	//ast -> Abstract Syntax Tree
 	if !ast.IsExported(s.name) {
 		//只有类型为exported(首字母大写)，才可以被注册
-		log.Fatalf("rpc server: %s is not a valie service name", s.name)
+		return nil, fmt.Errorf("rpc server: type %s is not exported, %q is not a valid service name", s.typ, s.name)
+	}
+	return s, nil
+}
+
+//checkServiceName检查自定义的服务名，服务名可以用"."分隔出命名空间，例如billing.v2.Invoice，
+//findService以最后一个"."分割服务名和方法名，因此每一段都不能为空，也不能包含空白字符
+func checkServiceName(name string) error {
+	if name == "" {
+		return errors.New("rpc server: empty service name")
+	}
+	if strings.IndexFunc(name, unicode.IsSpace) >= 0 {
+		return fmt.Errorf("rpc server: service name %q contains whitespace", name)
 	}
-	s.registerMethods()
-	return s
+	for _, part := range strings.Split(name, ".") {
+		if part == "" {
+			return fmt.Errorf("rpc server: service name %q has an empty segment", name)
+		}
+	}
+	return nil
 }
-func (s *service) registerMethods() {
+
+//registerMethods注册所有符合条件的方法，并返回每个被跳过的exported方法及其原因
+func (s *service) registerMethods() (skipped []string) {
 	s.method = make(map[string]*methodType)
-	//遍历寻找符合注册条件的方法
+	//遍历寻找符合注册条件的方法，reflect只会返回exported的方法
 	for i := 0; i < s.typ.NumMethod(); i++ {
 		method := s.typ.Method(i)
-		mType := method.Type
-		//如果该方法参数不为3个(第0个参数是自身，类似于python的self，java的this，则继续
-		if mType.NumIn() != 3 || mType.NumOut() != 1 {
-			continue
-		}
-		//如果该方法返回值不是error类型
-		if mType.Out(0) != reflect.TypeOf((*error)(nil)).Elem() {
-			continue
-		}
-		argType, replyType := mType.In(1), mType.In(2)
-		if !isExportedOrBuiltinType(argType) || !isExportedOrBuiltinType(replyType) {
+		//第0个参数是自身，类似于python的self，java的this
+		hasCtx, argType, replyType, err := checkMethodType(method.Type, 1)
+		if err != nil {
+			skipped = append(skipped, fmt.Sprintf("method %s.%s %v", s.name, method.Name, err))
 			continue
 		}
 		s.method[method.Name] = &methodType{
 			method:    method,
 			ArgType:   argType,
 			ReplyType: replyType,
+			hasCtx:    hasCtx,
 		}
-		log.Printf("rpc server: register %s.%s\n", s.name, method.Name)
 	}
+	return skipped
 }
+
+//checkMethodType检查一个函数类型是否满足注册条件，offset是args之前需要跳过的参数个数(方法的接收者为1)
+//满足条件的函数在offset个参数之后，依次接收可选的context.Context、args和*reply，并且只返回一个error
+func checkMethodType(mType reflect.Type, offset int) (hasCtx bool, argType, replyType reflect.Type, err error) {
+	numIn := mType.NumIn() - offset
+	//方法也可以在args之前额外接收一个context.Context
+	if numIn != 2 && numIn != 3 {
+		err = fmt.Errorf("has %d input parameters; needs (args, *reply) or (context.Context, args, *reply)", numIn)
+		return
+	}
+	hasCtx = numIn == 3
+	if hasCtx && mType.In(offset) != typeOfContext {
+		err = fmt.Errorf("first parameter is %s, not context.Context", mType.In(offset))
+		return
+	}
+	if mType.NumOut() != 1 {
+		err = fmt.Errorf("has %d output parameters; needs exactly one", mType.NumOut())
+		return
+	}
+	//如果该方法返回值不是error类型
+	if mType.Out(0) != typeOfError {
+		err = fmt.Errorf("returns %s, not error", mType.Out(0))
+		return
+	}
+	argType, replyType = mType.In(mType.NumIn()-2), mType.In(mType.NumIn()-1)
+	if !isExportedOrBuiltinType(argType) {
+		err = fmt.Errorf("argument type not exported: %s", argType)
+		return
+	}
+	if replyType.Kind() != reflect.Ptr {
+		err = fmt.Errorf("reply type not a pointer: %s", replyType)
+		return
+	}
+	if !isExportedOrBuiltinType(replyType) {
+		err = fmt.Errorf("reply type not exported: %s", replyType)
+		return
+	}
+	return
+}
+
 func isExportedOrBuiltinType(t reflect.Type) bool {
+	//指针类型本身没有名字，需要检查它指向的类型
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
 	//PkgPath()返回包名
 	return ast.IsExported(t.Name()) || t.PkgPath() == ""
 }
-func (s *service) call(m *methodType, argv, replyv reflect.Value) error {
+
+//ctx携带了请求的截止时间，并会在连接断开时被取消，只有第一个参数为context.Context的方法才会收到它
+func (s *service) call(ctx context.Context, m *methodType, argv, replyv reflect.Value) error {
+	atomic.AddUint64(&m.numCalls, 1)
 	f := m.method.Func
-	//[]reflect.Value{s.rcvr, argv, replyv}是go语言中的匿名数组
-	returnValues := f.Call([]reflect.Value{s.rcvr, argv, replyv})
+	//[]reflect.Value{argv, replyv}是go语言中的匿名数组
+	in := []reflect.Value{argv, replyv}
+	if m.hasCtx {
+		in = append([]reflect.Value{reflect.ValueOf(ctx)}, in...)
+	}
+	//通过RegisterFunc注册的service没有rcvr，函数不需要接收者作为第0个参数
+	if s.rcvr.IsValid() {
+		in = append([]reflect.Value{s.rcvr}, in...)
+	}
+	returnValues := f.Call(in)
 	if errInter := returnValues[0].Interface(); errInter != nil {
 		return errInter.(error)
 	}
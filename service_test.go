@@ -0,0 +1,259 @@
+package YARPC
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+//Calc的方法中只有Add符合注册条件，其余方法用于检查被跳过的原因
+type Calc struct{}
+
+func (c *Calc) Add(args [2]int, reply *int) error {
+	*reply = args[0] + args[1]
+	return nil
+}
+
+func (c *Calc) OneArg(args int) error { return nil }
+
+func (c *Calc) NoError(args int, reply *int) int { return 0 }
+
+func (c *Calc) ValueReply(args int, reply int) error { return nil }
+
+//Empty没有任何符合条件的方法
+type Empty struct{}
+
+func (e *Empty) OneArg(args int) error { return nil }
+
+func TestRegisterErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		rcvr interface{}
+		want []string
+	}{
+		{"nil", nil, []string{"nil receiver"}},
+		{"nil pointer", (*Calc)(nil), []string{"nil pointer receiver", "*YARPC.Calc"}},
+		{"unexported", newEchoService(), []string{"not exported", "echoService"}},
+		{"value of pointer methods", Calc{}, []string{"no exported methods", "hint: pass a pointer"}},
+		{"no suitable methods", &Empty{}, []string{"no exported methods", "Empty.OneArg has 1 input parameters"}},
+	}
+	for _, tt := range tests {
+		err := NewServer().Register(tt.rcvr)
+		if err == nil {
+			t.Errorf("%s: Register succeeded, want an error", tt.name)
+			continue
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("%s: err = %q, want it to contain %q", tt.name, err, want)
+			}
+		}
+	}
+}
+
+func TestRegisterSkipsUnsuitableMethods(t *testing.T) {
+	server := NewServer()
+	if err := server.Register(&Calc{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := server.findService("Calc.Add"); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"Calc.OneArg", "Calc.NoError", "Calc.ValueReply"} {
+		if _, _, err := server.findService(name); StatusCode(err) != NotFound {
+			t.Errorf("findService(%s) err = %v, want NotFound", name, err)
+		}
+	}
+}
+
+func TestStrictRegister(t *testing.T) {
+	server := NewServer()
+	server.SetStrictRegister(true)
+	err := server.Register(&Calc{})
+	if err == nil {
+		t.Fatal("Register succeeded in strict mode, want an error")
+	}
+	for _, want := range []string{
+		"Calc.OneArg has 1 input parameters",
+		"Calc.NoError returns int, not error",
+		"Calc.ValueReply reply type not a pointer: int",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("err = %q, want it to contain %q", err, want)
+		}
+	}
+	//严格模式下注册失败的服务不会被发布
+	if _, _, err := server.findService("Calc.Add"); StatusCode(err) != NotFound {
+		t.Fatalf("findService(Calc.Add) err = %v, want NotFound", err)
+	}
+}
+
+func TestRegisterName(t *testing.T) {
+	server := NewServer()
+	//同一个类型的多个实例，以及包含"."的服务名
+	for _, name := range []string{"Shard0", "Shard1", "billing.v2.Calc"} {
+		if err := server.RegisterName(name, &Calc{}); err != nil {
+			t.Fatalf("RegisterName(%q): %v", name, err)
+		}
+	}
+	client := dialEcho(t, serve(t, server))
+	for _, method := range []string{"Shard0.Add", "Shard1.Add", "billing.v2.Calc.Add"} {
+		var reply int
+		if err := client.Call(method, [2]int{1, 2}, &reply); err != nil || reply != 3 {
+			t.Fatalf("%s = %d, %v; want 3, nil", method, reply, err)
+		}
+	}
+	if err := server.RegisterName("Shard0", &Calc{}); err == nil || !strings.Contains(err.Error(), "already defined") {
+		t.Fatalf("duplicate RegisterName err = %v, want already defined", err)
+	}
+}
+
+func TestRegisterNameErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		rcvr interface{}
+		want string
+	}{
+		{"", &Calc{}, "empty service name"},
+		{"a b", &Calc{}, "contains whitespace"},
+		{"a..b", &Calc{}, "empty segment"},
+		{".a", &Calc{}, "empty segment"},
+		{"a.", &Calc{}, "empty segment"},
+		{"X", nil, "nil receiver"},
+		{"X", (*Calc)(nil), "nil pointer receiver"},
+	}
+	server := NewServer()
+	for _, tt := range tests {
+		if err := server.RegisterName(tt.name, tt.rcvr); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("RegisterName(%q, %#v) err = %v, want it to contain %q", tt.name, tt.rcvr, err, tt.want)
+		}
+	}
+	if _, _, err := server.findService("X.Add"); StatusCode(err) != NotFound {
+		t.Fatalf("findService(X.Add) err = %v, want NotFound", err)
+	}
+}
+
+func add(args [2]int, reply *int) error {
+	*reply = args[0] + args[1]
+	return nil
+}
+
+func sub(ctx context.Context, args [2]int, reply *int) error {
+	*reply = args[0] - args[1]
+	return nil
+}
+
+func TestRegisterFunc(t *testing.T) {
+	server := NewServer()
+	if err := server.RegisterFunc("Math.Add", add); err != nil {
+		t.Fatal(err)
+	}
+	client := dialEcho(t, serve(t, server))
+	var reply int
+	if err := client.Call("Math.Add", [2]int{1, 2}, &reply); err != nil || reply != 3 {
+		t.Fatalf("Math.Add = %d, %v; want 3, nil", reply, err)
+	}
+	//同一个service中的第二个函数，之前注册的函数仍然可用
+	if err := server.RegisterFunc("Math.Sub", sub); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Call("Math.Sub", [2]int{5, 2}, &reply); err != nil || reply != 3 {
+		t.Fatalf("Math.Sub = %d, %v; want 3, nil", reply, err)
+	}
+	if err := client.Call("Math.Add", [2]int{2, 2}, &reply); err != nil || reply != 4 {
+		t.Fatalf("Math.Add = %d, %v; want 4, nil", reply, err)
+	}
+}
+
+func TestRegisterFuncErrors(t *testing.T) {
+	server := NewServer()
+	if err := server.Register(&Calc{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.RegisterFunc("Math.Add", add); err != nil {
+		t.Fatal(err)
+	}
+	var nilFunc func(int, *int) error
+	tests := []struct {
+		serviceMethod string
+		fn            interface{}
+		want          string
+	}{
+		{"Calc.Sub", sub, "service already defined"},
+		{"Math.Add", add, "method already defined"},
+		{"Add", add, "must be of the form Service.Method"},
+		{"Math.", add, "empty segment"},
+		{"Math.Nil", nilFunc, "nil func"},
+		{"Math.NotFunc", 1, "not a function"},
+		{"Math.OneArg", func(int) error { return nil }, "has 1 input parameters"},
+		{"Math.NoError", func(int, *int) {}, "has 0 output parameters"},
+		{"Math.ValueReply", func(int, int) error { return nil }, "reply type not a pointer"},
+	}
+	for _, tt := range tests {
+		if err := server.RegisterFunc(tt.serviceMethod, tt.fn); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("RegisterFunc(%q) err = %v, want it to contain %q", tt.serviceMethod, err, tt.want)
+		}
+	}
+	//函数service和Register注册的service也不能重名
+	if err := server.Register(&Math{}); err == nil || !strings.Contains(err.Error(), "already defined") {
+		t.Errorf("Register(&Math{}) err = %v, want already defined", err)
+	}
+}
+
+//Math用于和同名的函数service冲突
+type Math struct{}
+
+func (m *Math) Mul(args [2]int, reply *int) error {
+	*reply = args[0] * args[1]
+	return nil
+}
+
+func TestRegisterFuncConcurrent(t *testing.T) {
+	//并发地向同一个service注册函数，不应丢失任何一个
+	server := NewServer()
+	const n = 20
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := server.RegisterFunc(fmt.Sprintf("Math.F%d", i), add); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	for i := 0; i < n; i++ {
+		if _, _, err := server.findService(fmt.Sprintf("Math.F%d", i)); err != nil {
+			t.Errorf("Math.F%d: %v", i, err)
+		}
+	}
+
+	//RegisterFunc和Replace竞争同一个服务名，Replace总是覆盖函数service，之后的RegisterFunc会失败，
+	//因此最终一定是只有struct方法的service，函数不会被合并进去
+	for i := 0; i < 50; i++ {
+		server := NewServer()
+		var wg sync.WaitGroup
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			_ = server.RegisterFunc("Math.Add", add)
+		}()
+		go func() {
+			defer wg.Done()
+			_ = server.RegisterFunc("Math.Sub", sub)
+		}()
+		go func() {
+			defer wg.Done()
+			_ = server.Replace("Math", &Math{})
+		}()
+		wg.Wait()
+		svci, _ := server.serviceMap.Load("Math")
+		s := svci.(*service)
+		if !s.rcvr.IsValid() || len(s.method) != 1 || s.method["Mul"] == nil {
+			t.Fatalf("service Math = %+v, want the struct service with only Mul", s)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package YARPC
+
+import (
+	"context"
+	"io"
+	"net"
+	"time"
+)
+
+//serverConn记录一个正在被ServeConn处理的连接
+type serverConn struct {
+	rwc    io.ReadWriteCloser
+	cancel context.CancelFunc //强制关闭时取消连接上所有请求的ctx
+}
+
+//trackListener在add为true时记录lis，Shutdown之后不再记录并返回false
+func (server *Server) trackListener(lis net.Listener, add bool) bool {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.listeners == nil {
+		server.listeners = make(map[net.Listener]struct{})
+	}
+	if !add {
+		delete(server.listeners, lis)
+		return true
+	}
+	if server.inShutdown {
+		return false
+	}
+	server.listeners[lis] = struct{}{}
+	return true
+}
+
+//trackConn在add为true时记录sc，Shutdown之后不再记录并返回false
+func (server *Server) trackConn(sc *serverConn, add bool) bool {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.conns == nil {
+		server.conns = make(map[*serverConn]struct{})
+	}
+	if !add {
+		delete(server.conns, sc)
+		server.connWg.Done()
+		return true
+	}
+	if server.inShutdown {
+		return false
+	}
+	server.conns[sc] = struct{}{}
+	server.connWg.Add(1)
+	return true
+}
+
+func (server *Server) shuttingDown() bool {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	return server.inShutdown
+}
+
+//Shutdown优雅地关闭服务端：
+//	1.关闭所有的listener，Accept随之返回
+//	2.已有的连接停止读取新的请求(需要连接支持SetReadDeadline，例如net.Conn)
+//	3.通过每个连接的WaitGroup等待正在处理的请求完成并发送回复
+//	4.关闭连接
+//ctx到期时强制关闭所有剩余的连接，取消正在处理的请求的ctx，并返回ctx.Err()
+func (server *Server) Shutdown(ctx context.Context) error {
+	server.mu.Lock()
+	server.inShutdown = true
+	for lis := range server.listeners {
+		_ = lis.Close()
+	}
+	for sc := range server.conns {
+		//使阻塞在读取上的serveCodec立即返回
+		if d, ok := sc.rwc.(interface{ SetReadDeadline(time.Time) error }); ok {
+			_ = d.SetReadDeadline(time.Now())
+		}
+	}
+	server.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		server.connWg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		server.mu.Lock()
+		for sc := range server.conns {
+			sc.cancel()
+			_ = sc.rwc.Close()
+		}
+		server.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+//为DefaultServer设置的Shutdown方法
+func Shutdown(ctx context.Context) error { return DefaultServer.Shutdown(ctx) }
@@ -0,0 +1,71 @@
+package YARPC
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestShutdownDrainsInFlight(t *testing.T) {
+	server, svc := NewServer(), newEchoService()
+	addr := startServer(t, server, svc)
+	client, err := Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+	var reply int
+	call := client.Go("Echo.Block", 1, &reply, nil)
+	<-svc.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	errc := make(chan error, 1)
+	go func() { errc <- server.Shutdown(ctx) }()
+	//请求仍在处理中，Shutdown不应返回
+	select {
+	case err := <-errc:
+		t.Fatalf("Shutdown returned %v before the in-flight call finished", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(svc.release)
+	if err := <-errc; err != nil {
+		t.Fatalf("Shutdown = %v, want nil", err)
+	}
+	<-call.Done
+	if call.Error != nil || reply != 1 {
+		t.Fatalf("call = %d, %v; want 1, nil", reply, call.Error)
+	}
+	if _, err := Dial("tcp", addr, &Option{ConnectTimeout: time.Second}); err == nil {
+		t.Fatal("Dial succeeded after Shutdown")
+	}
+}
+
+func TestShutdownForceClose(t *testing.T) {
+	server, svc := NewServer(), newEchoService()
+	defer close(svc.release)
+	client, err := Dial("tcp", startServer(t, server, svc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+	var reply int
+	call := client.Go("Echo.Block", 1, &reply, nil)
+	<-svc.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := server.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown = %v, want %v", err, context.DeadlineExceeded)
+	}
+	//连接被强制关闭，调用应该以错误结束而不是一直阻塞
+	select {
+	case <-call.Done:
+		if call.Error == nil {
+			t.Fatal("call succeeded after forced Shutdown")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("call not finished after forced Shutdown")
+	}
+}
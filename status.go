@@ -0,0 +1,130 @@
+package YARPC
+
+import (
+	"YARPC/codec"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+//Code是RPC错误的类别，取值与gRPC的状态码一致，便于与其他语言的实现互通
+type Code uint32
+
+const (
+	OK                 Code = 0  //没有错误
+	Canceled           Code = 1  //调用被调用方取消
+	Unknown            Code = 2  //未知错误，方法返回的普通error都属于这一类
+	InvalidArgument    Code = 3  //参数不合法
+	DeadlineExceeded   Code = 4  //调用在截止时间之前没有完成
+	NotFound           Code = 5  //请求的服务、方法或资源不存在
+	AlreadyExists      Code = 6  //要创建的资源已经存在
+	PermissionDenied   Code = 7  //没有权限
+	ResourceExhausted  Code = 8  //资源耗尽，例如超过了限流
+	FailedPrecondition Code = 9  //系统状态不满足调用的前提条件
+	Aborted            Code = 10 //调用被中止，例如并发冲突
+	Unimplemented      Code = 12 //方法没有实现
+	Internal           Code = 13 //服务端内部错误
+	Unavailable        Code = 14 //服务暂时不可用，可以重试
+	Unauthenticated    Code = 16 //没有通过身份认证
+)
+
+var codeNames = map[Code]string{
+	OK:                 "OK",
+	Canceled:           "Canceled",
+	Unknown:            "Unknown",
+	InvalidArgument:    "InvalidArgument",
+	DeadlineExceeded:   "DeadlineExceeded",
+	NotFound:           "NotFound",
+	AlreadyExists:      "AlreadyExists",
+	PermissionDenied:   "PermissionDenied",
+	ResourceExhausted:  "ResourceExhausted",
+	FailedPrecondition: "FailedPrecondition",
+	Aborted:            "Aborted",
+	Unimplemented:      "Unimplemented",
+	Internal:           "Internal",
+	Unavailable:        "Unavailable",
+	Unauthenticated:    "Unauthenticated",
+}
+
+func (c Code) String() string {
+	if name, ok := codeNames[c]; ok {
+		return name
+	}
+	return "Code(" + strconv.FormatUint(uint64(c), 10) + ")"
+}
+
+//Status是可以在网络上传递的结构化错误，服务端方法返回的*Status会被完整地还原到客户端，
+//客户端可以通过errors.As取得它，并根据Code判断错误的类别，而不需要匹配错误信息
+//Message写入Header.Error，Code和Details分别写入Header.ErrorCode和Header.ErrorDetails
+type Status struct {
+	Code    Code
+	Message string
+	Details []string
+}
+
+//Error只返回Message，与引入Status之前客户端得到的错误信息保持一致
+func (s *Status) Error() string {
+	return s.Message
+}
+
+//NewStatus创建一个*Status错误
+func NewStatus(code Code, message string, details ...string) *Status {
+	return &Status{Code: code, Message: message, Details: details}
+}
+
+//Errorf创建一个*Status错误，Message由format和a格式化得到
+func Errorf(code Code, format string, a ...interface{}) error {
+	return &Status{Code: code, Message: fmt.Sprintf(format, a...)}
+}
+
+//StatusFromError将err转换为*Status：
+//	-nil对应OK
+//	-错误链中包含*Status时返回它
+//	-context.DeadlineExceeded和context.Canceled分别对应DeadlineExceeded和Canceled
+//	-客户端的ErrShutdown对应Unavailable
+//	-其他错误对应Unknown
+func StatusFromError(err error) *Status {
+	if err == nil {
+		return &Status{Code: OK}
+	}
+	var st *Status
+	if errors.As(err, &st) {
+		return st
+	}
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return &Status{Code: DeadlineExceeded, Message: err.Error()}
+	case errors.Is(err, context.Canceled):
+		return &Status{Code: Canceled, Message: err.Error()}
+	case errors.Is(err, ErrShutdown):
+		return &Status{Code: Unavailable, Message: err.Error()}
+	}
+	return &Status{Code: Unknown, Message: err.Error()}
+}
+
+//StatusCode返回err对应的Code
+func StatusCode(err error) Code {
+	return StatusFromError(err).Code
+}
+
+//setHeaderError将err写入回复的Header
+func setHeaderError(h *codec.Header, err error) {
+	st := StatusFromError(err)
+	h.Error = st.Message
+	if h.Error == "" {
+		//Header.Error为空表示调用成功，因此不能为空
+		h.Error = st.Code.String()
+	}
+	h.ErrorCode = uint32(st.Code)
+	h.ErrorDetails = st.Details
+}
+
+//statusFromHeader在客户端根据回复的Header还原出*Status，旧版本的服务端不发送ErrorCode，此时为Unknown
+func statusFromHeader(h *codec.Header) *Status {
+	code := Code(h.ErrorCode)
+	if code == OK {
+		code = Unknown
+	}
+	return &Status{Code: code, Message: h.Error, Details: h.ErrorDetails}
+}
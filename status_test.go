@@ -0,0 +1,58 @@
+package YARPC
+
+import (
+	"YARPC/codec"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestStatusRoundTrip(t *testing.T) {
+	forEachCodec(t, startServer(t, NewServer(), newEchoService()), func(t *testing.T, typ codec.Type, client *Client) {
+		var reply wrapperspb.StringValue
+		err := client.Call("Echo.Fail", wrapperspb.String("abc"), &reply)
+		var st *Status
+		if !errors.As(err, &st) {
+			t.Fatalf("err = %#v, want a *Status", err)
+		}
+		want := &Status{Code: NotFound, Message: "echo: not found", Details: []string{"detail"}}
+		if !reflect.DeepEqual(st, want) {
+			t.Fatalf("status = %+v, want %+v", st, want)
+		}
+		//服务端自身产生的错误同样带有Code
+		if err := client.Call("Echo.Missing", wrapperspb.String("abc"), &reply); StatusCode(err) != NotFound {
+			t.Fatalf("Echo.Missing err = %v, want NotFound", err)
+		}
+		if err := client.Call("Echo", wrapperspb.String("abc"), &reply); StatusCode(err) != InvalidArgument {
+			t.Fatalf("Echo err = %v, want InvalidArgument", err)
+		}
+	})
+}
+
+func TestStatusFromError(t *testing.T) {
+	st := NewStatus(PermissionDenied, "denied")
+	tests := []struct {
+		err  error
+		want Code
+	}{
+		{nil, OK},
+		{st, PermissionDenied},
+		{fmt.Errorf("wrapped: %w", st), PermissionDenied},
+		{context.DeadlineExceeded, DeadlineExceeded},
+		{context.Canceled, Canceled},
+		{ErrShutdown, Unavailable},
+		{errors.New("plain"), Unknown},
+	}
+	for _, tt := range tests {
+		if got := StatusCode(tt.err); got != tt.want {
+			t.Errorf("StatusCode(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+	if got := StatusFromError(fmt.Errorf("wrapped: %w", st)); got != st {
+		t.Errorf("StatusFromError did not return the wrapped *Status: %+v", got)
+	}
+}
@@ -0,0 +1,128 @@
+package xclient
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+//SelectMode表示不同的负载均衡策略
+type SelectMode int
+
+const (
+	RandomSelect             SelectMode = iota //随机选择
+	RoundRobinSelect                           //轮询
+	WeightedRoundRobinSelect                   //平滑加权轮询，权重默认为1
+	P2CSelect                                  //随机选择两个，取正在处理的请求较少的一个(power of two choices)，由XClient根据负载选择
+)
+
+//Discovery是服务发现的接口，XClient通过它得到服务端的地址
+type Discovery interface {
+	Refresh() error                      //从注册中心或其他来源更新服务列表
+	Update(servers []string) error       //手动更新服务列表
+	Get(mode SelectMode) (string, error) //根据负载均衡策略，选择一个服务实例
+	GetAll() ([]string, error)           //返回所有的服务实例
+}
+
+var errNoServers = errors.New("rpc discovery: no available servers")
+
+//MultiServersDiscovery是一个不需要注册中心、由用户显式提供服务列表的Discovery，可以被并发使用
+type MultiServersDiscovery struct {
+	r       *rand.Rand //产生随机数
+	mu      sync.Mutex
+	servers []string
+	weights map[string]int //每个地址的权重，不存在时为1
+	current map[string]int //平滑加权轮询中每个地址的当前权重
+	index   int            //记录轮询到的位置
+}
+
+var _ Discovery = (*MultiServersDiscovery)(nil)
+
+func NewMultiServerDiscovery(servers []string) *MultiServersDiscovery {
+	d := &MultiServersDiscovery{
+		servers: append([]string(nil), servers...),
+		r:       rand.New(rand.NewSource(time.Now().UnixNano())),
+		weights: make(map[string]int),
+		current: make(map[string]int),
+	}
+	//为了避免每次都从0开始，初始化时随机设定一个值
+	d.index = d.r.Intn(1 << 30)
+	return d
+}
+
+//对于MultiServersDiscovery，Refresh没有意义，直接返回
+func (d *MultiServersDiscovery) Refresh() error {
+	return nil
+}
+
+//Update替换服务列表，已经设置的权重被保留
+func (d *MultiServersDiscovery) Update(servers []string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.servers = append([]string(nil), servers...)
+	d.current = make(map[string]int)
+	return nil
+}
+
+//SetWeights设置WeightedRoundRobinSelect使用的权重，没有设置或小于1的地址权重为1
+func (d *MultiServersDiscovery) SetWeights(weights map[string]int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.weights = make(map[string]int, len(weights))
+	for addr, w := range weights {
+		d.weights[addr] = w
+	}
+	d.current = make(map[string]int)
+}
+
+func (d *MultiServersDiscovery) weight(addr string) int {
+	if w := d.weights[addr]; w > 0 {
+		return w
+	}
+	return 1
+}
+
+//Get按照mode选出一个地址，对于P2CSelect，Discovery并不知道各地址的负载，因此随机返回一个
+func (d *MultiServersDiscovery) Get(mode SelectMode) (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	n := len(d.servers)
+	if n == 0 {
+		return "", errNoServers
+	}
+	switch mode {
+	case RandomSelect, P2CSelect:
+		return d.servers[d.r.Intn(n)], nil
+	case RoundRobinSelect:
+		//servers可能被更新，因此需要对n取模
+		s := d.servers[d.index%n]
+		d.index = (d.index + 1) % n
+		return s, nil
+	case WeightedRoundRobinSelect:
+		//平滑加权轮询：每个地址的当前权重加上其权重，选出当前权重最大的地址，再减去总权重
+		var best string
+		total := 0
+		for _, s := range d.servers {
+			w := d.weight(s)
+			total += w
+			d.current[s] += w
+			if best == "" || d.current[s] > d.current[best] {
+				best = s
+			}
+		}
+		d.current[best] -= total
+		return best, nil
+	default:
+		return "", errors.New("rpc discovery: not supported select mode")
+	}
+}
+
+//GetAll返回所有地址的一个副本
+func (d *MultiServersDiscovery) GetAll() ([]string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	servers := make([]string, len(d.servers))
+	copy(servers, d.servers)
+	return servers, nil
+}
@@ -0,0 +1,131 @@
+package xclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+//FileDiscovery从本地的JSON或YAML文件中读取服务列表，文件被修改后会在下一次Get/GetAll时重新读取
+//文件的格式如下(YAML与之对应)，weight可以省略，默认为1：
+//	{"servers": [{"addr": "tcp@10.0.0.1:9999", "weight": 2}, {"addr": "http@10.0.0.2:7001"}]}
+type FileDiscovery struct {
+	*MultiServersDiscovery
+	path       string
+	interval   time.Duration //两次检查文件之间的最短间隔
+	mu         sync.Mutex    //保护modTime和lastUpdate
+	modTime    time.Time     //上一次读取时文件的修改时间
+	lastUpdate time.Time     //上一次检查文件的时间
+}
+
+type fileServer struct {
+	Addr   string `json:"addr" yaml:"addr"`
+	Weight int    `json:"weight" yaml:"weight"`
+}
+
+type fileServers struct {
+	Servers []fileServer `json:"servers" yaml:"servers"`
+}
+
+const defaultFileCheckInterval = time.Second * 5
+
+var _ Discovery = (*FileDiscovery)(nil)
+
+//NewFileDiscovery创建一个FileDiscovery并立即读取一次文件，interval为0时使用默认值
+//文件扩展名为.yaml或.yml时按YAML解析，否则按JSON解析
+func NewFileDiscovery(path string, interval time.Duration) (*FileDiscovery, error) {
+	if interval == 0 {
+		interval = defaultFileCheckInterval
+	}
+	d := &FileDiscovery{
+		MultiServersDiscovery: NewMultiServerDiscovery(nil),
+		path:                  path,
+		interval:              interval,
+	}
+	if err := d.load(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+//load在文件的修改时间变化时重新读取文件，并更新服务列表和权重
+func (d *FileDiscovery) load() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.lastUpdate = time.Now()
+	info, err := os.Stat(d.path)
+	if err != nil {
+		return err
+	}
+	if info.ModTime().Equal(d.modTime) {
+		return nil
+	}
+	data, err := os.ReadFile(d.path)
+	if err != nil {
+		return err
+	}
+	var fs fileServers
+	switch strings.ToLower(filepath.Ext(d.path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &fs)
+	default:
+		err = json.Unmarshal(data, &fs)
+	}
+	if err != nil {
+		return fmt.Errorf("rpc discovery: parse %s: %v", d.path, err)
+	}
+	servers := make([]string, 0, len(fs.Servers))
+	weights := make(map[string]int, len(fs.Servers))
+	for _, s := range fs.Servers {
+		if s.Addr == "" {
+			continue
+		}
+		servers = append(servers, s.Addr)
+		weights[s.Addr] = s.Weight
+	}
+	d.SetWeights(weights)
+	_ = d.MultiServersDiscovery.Update(servers)
+	d.modTime = info.ModTime()
+	return nil
+}
+
+//Refresh在距离上一次检查超过interval时重新检查文件
+func (d *FileDiscovery) Refresh() error {
+	d.mu.Lock()
+	due := d.lastUpdate.Add(d.interval).Before(time.Now())
+	d.mu.Unlock()
+	if !due {
+		return nil
+	}
+	return d.load()
+}
+
+//Update手动更新服务列表，文件下一次被修改时会覆盖这里的设置
+func (d *FileDiscovery) Update(servers []string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.lastUpdate = time.Now()
+	return d.MultiServersDiscovery.Update(servers)
+}
+
+//Get和GetAll在文件暂时无法读取或解析时(例如正在被编辑)，继续使用上一次读取到的服务列表
+func (d *FileDiscovery) Get(mode SelectMode) (string, error) {
+	if err := d.Refresh(); err != nil {
+		log.Println("rpc discovery: refresh err:", err)
+	}
+	return d.MultiServersDiscovery.Get(mode)
+}
+
+func (d *FileDiscovery) GetAll() ([]string, error) {
+	if err := d.Refresh(); err != nil {
+		log.Println("rpc discovery: refresh err:", err)
+	}
+	return d.MultiServersDiscovery.GetAll()
+}
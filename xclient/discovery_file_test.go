@@ -0,0 +1,78 @@
+package xclient
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+//writeFile写入文件，并将修改时间设置为mtime，避免两次写入落在文件系统时间戳的同一个精度内
+func writeFile(t *testing.T, path, content string, mtime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFileDiscoveryReloads(t *testing.T) {
+	for _, tc := range []struct {
+		name, v1, v2 string
+	}{
+		{
+			name: "servers.json",
+			v1:   `{"servers": [{"addr": "tcp@a", "weight": 3}, {"addr": "tcp@b"}]}`,
+			v2:   `{"servers": [{"addr": "tcp@b"}, {"addr": "tcp@c", "weight": 3}]}`,
+		},
+		{
+			name: "servers.yaml",
+			v1:   "servers:\n  - addr: tcp@a\n    weight: 3\n  - addr: tcp@b\n",
+			v2:   "servers:\n  - addr: tcp@b\n  - addr: tcp@c\n    weight: 3\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tc.name)
+			mtime := time.Now().Add(-time.Hour)
+			writeFile(t, path, tc.v1, mtime)
+			d, err := NewFileDiscovery(path, time.Millisecond)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkServers(t, d, []string{"tcp@a", "tcp@b"}, "tcp@a")
+
+			writeFile(t, path, tc.v2, mtime.Add(time.Minute))
+			time.Sleep(5 * time.Millisecond)
+			checkServers(t, d, []string{"tcp@b", "tcp@c"}, "tcp@c")
+
+			//文件暂时无法解析时继续使用上一次的服务列表
+			writeFile(t, path, "{not valid", mtime.Add(2*time.Minute))
+			time.Sleep(5 * time.Millisecond)
+			checkServers(t, d, []string{"tcp@b", "tcp@c"}, "tcp@c")
+		})
+	}
+}
+
+//checkServers检查d的服务列表，并检查权重为3的heavy在4次加权轮询中被选中3次
+func checkServers(t *testing.T, d Discovery, want []string, heavy string) {
+	t.Helper()
+	got, err := d.GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("GetAll = %v, want %v", got, want)
+	}
+	n := 0
+	for i := 0; i < 4; i++ {
+		if s, _ := d.Get(WeightedRoundRobinSelect); s == heavy {
+			n++
+		}
+	}
+	if n != 3 {
+		t.Fatalf("%s picked %d times in 4, want 3", heavy, n)
+	}
+}
@@ -0,0 +1,104 @@
+package xclient
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+//RegistryDiscovery从registry包提供的注册中心获取服务列表，距离上一次获取超过timeout时重新获取
+type RegistryDiscovery struct {
+	*MultiServersDiscovery
+	registry   string        //注册中心的地址
+	timeout    time.Duration //服务列表的过期时间
+	mu         sync.Mutex    //保护lastUpdate和refreshing
+	lastUpdate time.Time     //上一次从注册中心获取服务列表的时间
+	refreshing bool          //是否有协程正在向注册中心请求服务列表
+}
+
+const defaultUpdateTimeout = time.Second * 10
+
+var _ Discovery = (*RegistryDiscovery)(nil)
+
+func NewRegistryDiscovery(registerAddr string, timeout time.Duration) *RegistryDiscovery {
+	if timeout == 0 {
+		timeout = defaultUpdateTimeout
+	}
+	return &RegistryDiscovery{
+		MultiServersDiscovery: NewMultiServerDiscovery(make([]string, 0)),
+		registry:              registerAddr,
+		timeout:               timeout,
+	}
+}
+
+func (d *RegistryDiscovery) Update(servers []string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.lastUpdate = time.Now()
+	return d.MultiServersDiscovery.Update(servers)
+}
+
+//Refresh在服务列表过期时，向注册中心发送GET请求获取最新的服务列表
+//请求期间不持有d.mu，同一时间只有一个协程请求注册中心，其他协程直接使用已有的服务列表
+func (d *RegistryDiscovery) Refresh() error {
+	d.mu.Lock()
+	if d.refreshing || d.lastUpdate.Add(d.timeout).After(time.Now()) {
+		d.mu.Unlock()
+		return nil
+	}
+	d.refreshing = true
+	d.mu.Unlock()
+
+	servers, err := d.fetch()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.refreshing = false
+	if err != nil {
+		//获取失败时不更新lastUpdate，下一次调用会再次尝试
+		return err
+	}
+	if err := d.MultiServersDiscovery.Update(servers); err != nil {
+		return err
+	}
+	d.lastUpdate = time.Now()
+	return nil
+}
+
+//fetch向注册中心发送GET请求，返回X-Yarpc-Servers中的服务列表
+func (d *RegistryDiscovery) fetch() ([]string, error) {
+	log.Println("rpc registry: refresh servers from registry", d.registry)
+	httpClient := &http.Client{Timeout: time.Second * 10}
+	resp, err := httpClient.Get(d.registry)
+	if err != nil {
+		return nil, err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("rpc registry refresh: unexpected status %s", resp.Status)
+	}
+	servers := make([]string, 0)
+	for _, server := range strings.Split(resp.Header.Get("X-Yarpc-Servers"), ",") {
+		if server = strings.TrimSpace(server); server != "" {
+			servers = append(servers, server)
+		}
+	}
+	return servers, nil
+}
+
+//Get和GetAll在注册中心暂时不可用时，继续使用上一次获取到的服务列表
+func (d *RegistryDiscovery) Get(mode SelectMode) (string, error) {
+	if err := d.Refresh(); err != nil {
+		log.Println("rpc registry refresh err:", err)
+	}
+	return d.MultiServersDiscovery.Get(mode)
+}
+
+func (d *RegistryDiscovery) GetAll() ([]string, error) {
+	if err := d.Refresh(); err != nil {
+		log.Println("rpc registry refresh err:", err)
+	}
+	return d.MultiServersDiscovery.GetAll()
+}
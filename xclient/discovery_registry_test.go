@@ -0,0 +1,32 @@
+package xclient
+
+import (
+	"YARPC/registry"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRegistryDiscoveryServesCachedServers(t *testing.T) {
+	ts := httptest.NewServer(registry.New(0))
+	stop := registry.Heartbeat(ts.URL, "tcp@a", time.Hour)
+	stop()
+	d := NewRegistryDiscovery(ts.URL, 10*time.Millisecond)
+	if got, err := d.GetAll(); err != nil || !reflect.DeepEqual(got, []string{"tcp@a"}) {
+		t.Fatalf("GetAll = %v, %v; want [tcp@a], nil", got, err)
+	}
+
+	//注册中心不可用时，继续使用上一次获取到的服务列表
+	ts.Close()
+	time.Sleep(20 * time.Millisecond)
+	if got, err := d.GetAll(); err != nil || !reflect.DeepEqual(got, []string{"tcp@a"}) {
+		t.Fatalf("GetAll = %v, %v after the registry failed; want [tcp@a], nil", got, err)
+	}
+	if got, err := d.Get(RandomSelect); err != nil || got != "tcp@a" {
+		t.Fatalf("Get = %q, %v after the registry failed; want tcp@a, nil", got, err)
+	}
+	if err := d.Refresh(); err == nil {
+		t.Fatal("Refresh succeeded with the registry down")
+	}
+}
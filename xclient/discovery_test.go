@@ -0,0 +1,65 @@
+package xclient
+
+import "testing"
+
+func TestRoundRobinSelect(t *testing.T) {
+	servers := []string{"tcp@a", "tcp@b", "tcp@c"}
+	d := NewMultiServerDiscovery(servers)
+	first, err := d.Get(RoundRobinSelect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//起始位置是随机的，之后按照列表的顺序循环
+	i := indexOf(servers, first)
+	for n := 0; n < 2*len(servers); n++ {
+		i = (i + 1) % len(servers)
+		got, _ := d.Get(RoundRobinSelect)
+		if got != servers[i] {
+			t.Fatalf("pick %d = %s, want %s", n, got, servers[i])
+		}
+	}
+}
+
+func TestWeightedRoundRobinSelect(t *testing.T) {
+	d := NewMultiServerDiscovery([]string{"tcp@a", "tcp@b", "tcp@c"})
+	d.SetWeights(map[string]int{"tcp@a": 5, "tcp@b": 1})
+	//平滑加权轮询以总权重为周期，每个周期内的选择次数等于权重，并且权重大的地址不会被连续选中太多次
+	want := []string{"tcp@a", "tcp@a", "tcp@b", "tcp@a", "tcp@c", "tcp@a", "tcp@a"}
+	for round := 0; round < 3; round++ {
+		for i, w := range want {
+			if got, _ := d.Get(WeightedRoundRobinSelect); got != w {
+				t.Fatalf("round %d pick %d = %s, want %s", round, i, got, w)
+			}
+		}
+	}
+	//更新服务列表后权重仍然保留
+	if err := d.Update([]string{"tcp@a", "tcp@b"}); err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[string]int)
+	for i := 0; i < 12; i++ {
+		got, _ := d.Get(WeightedRoundRobinSelect)
+		counts[got]++
+	}
+	if counts["tcp@a"] != 10 || counts["tcp@b"] != 2 {
+		t.Fatalf("counts = %v, want tcp@a:10 tcp@b:2", counts)
+	}
+}
+
+func TestGetWithoutServers(t *testing.T) {
+	d := NewMultiServerDiscovery(nil)
+	for _, mode := range []SelectMode{RandomSelect, RoundRobinSelect, WeightedRoundRobinSelect, P2CSelect} {
+		if _, err := d.Get(mode); err != errNoServers {
+			t.Errorf("Get(%d) err = %v, want %v", mode, err, errNoServers)
+		}
+	}
+}
+
+func indexOf(servers []string, s string) int {
+	for i, server := range servers {
+		if server == s {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,174 @@
+package xclient
+
+import (
+	. "YARPC"
+	"context"
+	"io"
+	"math/rand"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+//XClient将多个服务端的*Client封装在一个Call之后，通过Discovery得到服务端地址，并按照SelectMode选择服务端
+//地址的格式为protocol@addr，与XDial相同
+type XClient struct {
+	d        Discovery
+	mode     SelectMode
+	opt      *Option
+	mu       sync.Mutex
+	clients  map[string]*Client //按地址缓存已经建立的连接，以便复用
+	inflight sync.Map           //每个地址正在处理的请求数，值为*int64，P2CSelect根据它选择负载较小的地址
+}
+
+var _ io.Closer = (*XClient)(nil)
+
+func NewXClient(d Discovery, mode SelectMode, opt *Option) *XClient {
+	return &XClient{
+		d:       d,
+		mode:    mode,
+		opt:     opt,
+		clients: make(map[string]*Client),
+	}
+}
+
+//Close关闭所有缓存的连接
+func (xc *XClient) Close() error {
+	xc.mu.Lock()
+	defer xc.mu.Unlock()
+	for key, client := range xc.clients {
+		//忽略错误
+		_ = client.Close()
+		delete(xc.clients, key)
+	}
+	return nil
+}
+
+//dial返回rpcAddr对应的缓存的Client，缓存的Client不可用时将其关闭并重新建立连接
+func (xc *XClient) dial(rpcAddr string) (*Client, error) {
+	xc.mu.Lock()
+	client, ok := xc.clients[rpcAddr]
+	if ok && !client.IsAvailable() {
+		_ = client.Close()
+		delete(xc.clients, rpcAddr)
+		client = nil
+	}
+	xc.mu.Unlock()
+	if client != nil {
+		return client, nil
+	}
+	//建立连接最长可能需要ConnectTimeout，期间不持有xc.mu，避免一个不可达的服务端阻塞对其他服务端的调用
+	client, err := XDial(rpcAddr, xc.opt)
+	if err != nil {
+		return nil, err
+	}
+	xc.mu.Lock()
+	defer xc.mu.Unlock()
+	//其他协程可能同时完成了对rpcAddr的连接，此时使用先缓存的Client，关闭多余的连接
+	if cached, ok := xc.clients[rpcAddr]; ok {
+		if cached.IsAvailable() {
+			_ = client.Close()
+			return cached, nil
+		}
+		_ = cached.Close()
+	}
+	xc.clients[rpcAddr] = client
+	return client, nil
+}
+
+func (xc *XClient) load(rpcAddr string) *int64 {
+	n, _ := xc.inflight.LoadOrStore(rpcAddr, new(int64))
+	return n.(*int64)
+}
+
+//pick按照xc.mode选出一个地址，P2CSelect需要各地址的负载，因此由XClient从GetAll的结果中选择
+func (xc *XClient) pick() (string, error) {
+	if xc.mode != P2CSelect {
+		return xc.d.Get(xc.mode)
+	}
+	servers, err := xc.d.GetAll()
+	if err != nil {
+		return "", err
+	}
+	switch n := len(servers); n {
+	case 0:
+		return "", errNoServers
+	case 1:
+		return servers[0], nil
+	default:
+		//随机选出两个不同的地址，取正在处理的请求较少的一个
+		i := rand.Intn(n)
+		j := rand.Intn(n - 1)
+		if j >= i {
+			j++
+		}
+		a, b := servers[i], servers[j]
+		if atomic.LoadInt64(xc.load(b)) < atomic.LoadInt64(xc.load(a)) {
+			return b, nil
+		}
+		return a, nil
+	}
+}
+
+func (xc *XClient) call(rpcAddr string, ctx context.Context, serviceMethod string, args, reply interface{}) error {
+	client, err := xc.dial(rpcAddr)
+	if err != nil {
+		return err
+	}
+	n := xc.load(rpcAddr)
+	atomic.AddInt64(n, 1)
+	defer atomic.AddInt64(n, -1)
+	return client.CallContext(ctx, serviceMethod, args, reply)
+}
+
+//Call按照负载均衡策略选出一个服务端，并在其上调用serviceMethod
+func (xc *XClient) Call(ctx context.Context, serviceMethod string, args, reply interface{}) error {
+	rpcAddr, err := xc.pick()
+	if err != nil {
+		return err
+	}
+	return xc.call(rpcAddr, ctx, serviceMethod, args, reply)
+}
+
+//Broadcast并发地在所有服务端上调用serviceMethod
+//任意一个调用失败时返回第一个错误，并取消其他仍未完成的调用；全部成功时，将其中一个结果拷贝到reply中
+func (xc *XClient) Broadcast(ctx context.Context, serviceMethod string, args, reply interface{}) error {
+	servers, err := xc.d.GetAll()
+	if err != nil {
+		return err
+	}
+	if len(servers) == 0 {
+		return errNoServers
+	}
+	var wg sync.WaitGroup
+	var mu sync.Mutex //保护e和replyDone
+	var e error
+	replyDone := reply == nil //reply为nil时无需设置
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	for _, rpcAddr := range servers {
+		wg.Add(1)
+		go func(rpcAddr string) {
+			defer wg.Done()
+			//每个调用使用各自的reply，避免并发写入同一个reply
+			var clonedReply interface{}
+			if reply != nil {
+				clonedReply = reflect.New(reflect.ValueOf(reply).Elem().Type()).Interface()
+			}
+			err := xc.call(rpcAddr, ctx, serviceMethod, args, clonedReply)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil && e == nil {
+				e = err
+				//有一个调用失败时，取消其他的调用
+				cancel()
+			}
+			if err == nil && !replyDone {
+				reflect.ValueOf(reply).Elem().Set(reflect.ValueOf(clonedReply).Elem())
+				replyDone = true
+			}
+		}(rpcAddr)
+	}
+	wg.Wait()
+	return e
+}
@@ -0,0 +1,136 @@
+package xclient
+
+import (
+	. "YARPC"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+//Node是测试使用的服务，每个服务端上的Node拥有不同的ID
+type Node struct {
+	ID      int
+	fail    bool          //为true时Info返回错误
+	release chan struct{} //不为nil时，Info在release被关闭之前一直阻塞
+}
+
+type NodeInfo struct {
+	ID   int
+	Name string
+}
+
+func (n *Node) Info(args int, reply *NodeInfo) error {
+	if n.fail {
+		return errors.New("node: fail")
+	}
+	if n.release != nil {
+		<-n.release
+	}
+	reply.ID = n.ID
+	reply.Name = fmt.Sprintf("node-%d", n.ID)
+	return nil
+}
+
+//startNode在一个随机端口上运行注册了node的服务端，返回tcp@addr形式的地址
+func startNode(t *testing.T, node *Node) string {
+	t.Helper()
+	server := NewServer()
+	if err := server.Register(node); err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Accept(l)
+	t.Cleanup(func() { _ = l.Close() })
+	return "tcp@" + l.Addr().String()
+}
+
+func newTestXClient(t *testing.T, mode SelectMode, servers ...string) *XClient {
+	t.Helper()
+	xc := NewXClient(NewMultiServerDiscovery(servers), mode, nil)
+	t.Cleanup(func() { _ = xc.Close() })
+	return xc
+}
+
+func TestP2CSelectPicksLessLoaded(t *testing.T) {
+	xc := newTestXClient(t, P2CSelect, "tcp@a", "tcp@b", "tcp@c")
+	atomic.StoreInt64(xc.load("tcp@a"), 3)
+	atomic.StoreInt64(xc.load("tcp@c"), 1)
+	counts := make(map[string]int)
+	for i := 0; i < 200; i++ {
+		addr, err := xc.pick()
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts[addr]++
+	}
+	//a的负载最高，无论和谁比较都不会被选中；b的负载最低，只要被随机到就会被选中
+	if counts["tcp@a"] != 0 || counts["tcp@b"] <= counts["tcp@c"] {
+		t.Fatalf("counts = %v, want a never picked and b picked more than c", counts)
+	}
+}
+
+func TestDialReplacesUnavailableClient(t *testing.T) {
+	addr := startNode(t, &Node{ID: 1})
+	xc := newTestXClient(t, RandomSelect, addr)
+	var reply NodeInfo
+	if err := xc.Call(context.Background(), "Node.Info", 0, &reply); err != nil {
+		t.Fatal(err)
+	}
+	xc.mu.Lock()
+	old := xc.clients[addr]
+	xc.mu.Unlock()
+	_ = old.Close()
+
+	if err := xc.Call(context.Background(), "Node.Info", 0, &reply); err != nil {
+		t.Fatalf("Call after the cached client was closed: %v", err)
+	}
+	xc.mu.Lock()
+	cur := xc.clients[addr]
+	xc.mu.Unlock()
+	if cur == old || !cur.IsAvailable() {
+		t.Fatal("unavailable client was not replaced")
+	}
+}
+
+func TestBroadcastCopiesOneReply(t *testing.T) {
+	xc := newTestXClient(t, RandomSelect, startNode(t, &Node{ID: 1}), startNode(t, &Node{ID: 2}), startNode(t, &Node{ID: 3}))
+	var reply NodeInfo
+	if err := xc.Broadcast(context.Background(), "Node.Info", 0, &reply); err != nil {
+		t.Fatal(err)
+	}
+	//reply来自某一个服务端，而不是多个回复混合的结果
+	if reply.ID < 1 || reply.ID > 3 || reply.Name != fmt.Sprintf("node-%d", reply.ID) {
+		t.Fatalf("reply = %+v, want the reply of exactly one node", reply)
+	}
+	//reply为nil时只检查错误
+	if err := xc.Broadcast(context.Background(), "Node.Info", 0, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBroadcastCancelsOnFirstError(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	xc := newTestXClient(t, RandomSelect, startNode(t, &Node{ID: 1, release: release}), startNode(t, &Node{ID: 2, fail: true}))
+	done := make(chan error, 1)
+	go func() {
+		var reply NodeInfo
+		done <- xc.Broadcast(context.Background(), "Node.Info", 0, &reply)
+	}()
+	//阻塞的调用被取消，Broadcast不需要等待它完成
+	select {
+	case err := <-done:
+		if err == nil || err.Error() != "node: fail" {
+			t.Fatalf("err = %v, want node: fail", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Broadcast still waiting for the blocked call after another call failed")
+	}
+}